@@ -1,7 +1,10 @@
 import asyncio
+import hashlib
 import json
 import logging
+import mimetypes
 import os
+import tempfile
 
 import aio_pika
 import httpx
@@ -13,22 +16,44 @@ from app.tasks.tts import generate_audio
 
 logger = logging.getLogger(__name__)
 
+# Job kinds this worker has a pipeline for. Messages without a kind predate
+# job kinds and are audio translations. The gateway's ENABLED_JOB_KINDS must
+# not list anything missing here.
+SUPPORTED_KINDS = {"audio_translation"}
+
 class Worker:
     def __init__(self, config):
         self.config = config
         self.connection = None
         self.channel = None
         self.queue = None
+        self.cancel_queue = None
         self.is_running = False
         self.http_client = httpx.AsyncClient(timeout=30.0)
+        self.transcript_locks = {}
+        # Jobs this worker is running, and the ones asked to stop.
+        self.running_jobs = {}
+        self.cancelled_jobs = set()
     
     async def connect(self):
         self.connection = await aio_pika.connect_robust(self.config.rabbitmq_url)
         self.channel = await self.connection.channel()
+        # Must match the arguments the API gateway declares the queue with.
         self.queue = await self.channel.declare_queue(
             self.config.rabbitmq_queue,
-            durable=True
+            durable=True,
+            arguments={
+                "x-dead-letter-exchange": self.config.rabbitmq_dlx,
+                "x-dead-letter-routing-key": self.config.rabbitmq_queue,
+            },
+        )
+        # Every worker gets every cancellation on its own queue; only the one
+        # running the job acts on it.
+        cancel_exchange = await self.channel.declare_exchange(
+            self.config.rabbitmq_cancel_exchange, aio_pika.ExchangeType.FANOUT, durable=True
         )
+        self.cancel_queue = await self.channel.declare_queue(exclusive=True)
+        await self.cancel_queue.bind(cancel_exchange)
         logger.info(f"Connected to RabbitMQ queue: {self.config.rabbitmq_queue}")
     
     async def start(self):
@@ -36,6 +61,7 @@ class Worker:
         try:
             await self.connect()
             await self.queue.consume(self.process_message, no_ack=False)
+            await self.cancel_queue.consume(self.process_cancel, no_ack=True)
             logger.info("Worker started consuming messages")
             
             while self.is_running:
@@ -56,51 +82,220 @@ class Worker:
         logger.info("Worker stopped")
     
     async def process_message(self, message: aio_pika.IncomingMessage):
+        try:
+            job_data = json.loads(message.body.decode())
+        except Exception as e:
+            # Unparseable messages go straight to the dead-letter queue.
+            logger.error(f"Error decoding message: {e}")
+            await message.reject(requeue=False)
+            return
+
+        kind = job_data.get("kind", "audio_translation")
+        if kind not in SUPPORTED_KINDS:
+            # Retrying cannot help; park the message in the dead-letter queue
+            # where it can be replayed once a worker handles the kind.
+            reason = f"unsupported job kind: {kind}"
+            logger.error(f"Job {job_data.get('job_id')}: {reason}")
+            await self.update_job_status(job_data.get("job_id"), "failed", error=reason)
+            await message.reject(requeue=False)
+            return
+
+        job_id = job_data.get("job_id")
+        logger.info(f"Processing job: {job_id}")
         async with message.process():
+            task = asyncio.create_task(self.process_job(job_data, message))
+            self.running_jobs[job_id] = task
             try:
-                job_data = json.loads(message.body.decode())
-                logger.info(f"Processing job: {job_data.get('job_id')}")
-                await self.process_job(job_data)
-            except Exception as e:
-                logger.error(f"Error processing message: {e}")
-    
-    async def process_job(self, job_data):
+                await task
+            except asyncio.CancelledError:
+                # A cancelled job is acked and dropped; anything else
+                # cancelling us, such as shutdown, carries on up.
+                if job_id not in self.cancelled_jobs:
+                    raise
+                logger.info(f"Job {job_id} cancelled")
+            finally:
+                self.running_jobs.pop(job_id, None)
+                self.cancelled_jobs.discard(job_id)
+
+    async def process_cancel(self, message: aio_pika.IncomingMessage):
+        # The gateway routes cancellations by job ID.
+        self.abort_job(message.routing_key)
+
+    def abort_job(self, job_id):
+        task = self.running_jobs.get(job_id)
+        if task and not task.done():
+            logger.info(f"Stopping job {job_id}")
+            self.cancelled_jobs.add(job_id)
+            task.cancel()
+
+    async def process_job(self, job_data, message):
         job_id = job_data["job_id"]
         
+        heartbeat = None
         try:
-            await self.update_job_status(job_id, "processing")
+            if await self.update_job_status(job_id, "processing") == 409:
+                # Cancelled or otherwise settled while it waited in the queue.
+                logger.info(f"Job {job_id} can no longer run; dropping it")
+                return
+            heartbeat = asyncio.create_task(self.heartbeat(job_id))
             
-            source_path = job_data["source_file"]
             source_lang = job_data["source_lang"]
             target_lang = job_data["target_lang"]
-            result_path = os.path.join(self.config.results_path, f"{job_id}.wav")
-            
-            transcription = await transcribe_audio(source_path, source_lang, self.config)
-            translation = await translate_text(transcription, source_lang, target_lang, self.config)
-            audio_path = await generate_audio(translation, target_lang, result_path, self.config)
-            
-            result_url = f"/results/{os.path.basename(audio_path)}"
-            await self.update_job_status(job_id, "succeeded", result_url=result_url)
+            result_key = job_data["result_key"]
+
+            # Sources and results go through the gateway's storage, so this
+            # worker need not share a disk with it.
+            with tempfile.TemporaryDirectory(prefix=f"job-{job_id}-") as workdir:
+                source_path = os.path.join(workdir, "source")
+                await self.download_source(job_data, source_path)
+
+                await self.report_progress(job_id, "transcribe", 0)
+                transcription = await self.transcribe(job_data, source_path, source_lang)
+                await self.report_progress(job_id, "translate", 40)
+                translation = await translate_text(transcription, source_lang, target_lang, self.config)
+                await self.report_progress(job_id, "synthesize", 60)
+                result_path = os.path.join(workdir, os.path.basename(result_key))
+                audio_path = await generate_audio(translation, target_lang, result_path, self.config)
+                await self.upload_result(job_data, audio_path)
+
+            heartbeat.cancel()
+            await self.update_job_status(job_id, "completed", result_url=result_key)
             logger.info(f"Job {job_id} completed")
             
+        except asyncio.CancelledError:
+            if heartbeat:
+                heartbeat.cancel()
+            raise
         except Exception as e:
+            if heartbeat:
+                heartbeat.cancel()
             logger.error(f"Job {job_id} failed: {e}")
-            await self.update_job_status(job_id, "failed", error=str(e))
+            await self.retry_or_dead_letter(job_id, message, str(e))
+
+    async def download_source(self, job_data, path):
+        # source_url is signed by the gateway's storage driver and is valid
+        # for a day, which a DLQ replay renews.
+        hasher = hashlib.sha256()
+        async with self.http_client.stream("GET", self.storage_url(job_data["source_url"]), timeout=None) as response:
+            response.raise_for_status()
+            with open(path, "wb") as f:
+                async for chunk in response.aiter_bytes():
+                    hasher.update(chunk)
+                    f.write(chunk)
+
+        expected = job_data.get("source_checksum")
+        if expected and hasher.hexdigest() != expected:
+            raise ValueError("downloaded source does not match its checksum")
+
+    async def upload_result(self, job_data, path):
+        # S3 refuses chunked PUTs, so the length is sent up front.
+        async def chunks():
+            with open(path, "rb") as f:
+                while chunk := f.read(1 << 20):
+                    yield chunk
+
+        headers = {
+            "Content-Type": mimetypes.guess_type(job_data["result_key"])[0] or "application/octet-stream",
+            "Content-Length": str(os.path.getsize(path)),
+        }
+        response = await self.http_client.put(
+            self.storage_url(job_data["result_upload_url"]), content=chunks(), headers=headers, timeout=None
+        )
+        response.raise_for_status()
+
+    def storage_url(self, url):
+        # URLs signed by the local driver point at the gateway's public
+        # address, which may not be reachable from here. S3 URLs pass through.
+        public = self.config.public_url.rstrip("/")
+        if url.startswith(public + "/"):
+            return self.config.api_base_url.rstrip("/") + url[len(public):]
+        return url
+
+    async def transcribe(self, job_data, source_path, source_lang):
+        # Children of a multi-language job share one source, so the first to
+        # get here transcribes it and the rest reuse the result.
+        parent_id = job_data.get("parent_id")
+        if not parent_id:
+            return await transcribe_audio(source_path, source_lang, self.config)
+
+        cache_path = os.path.join(self.config.results_path, "transcripts", f"{parent_id}.txt")
+        lock = self.transcript_locks.setdefault(parent_id, asyncio.Lock())
+        async with lock:
+            if os.path.exists(cache_path):
+                with open(cache_path, encoding="utf-8") as f:
+                    return f.read()
+            transcription = await transcribe_audio(source_path, source_lang, self.config)
+            os.makedirs(os.path.dirname(cache_path), exist_ok=True)
+            tmp_path = f"{cache_path}.{os.getpid()}.tmp"
+            with open(tmp_path, "w", encoding="utf-8") as f:
+                f.write(transcription)
+            os.replace(tmp_path, cache_path)
+            return transcription
+
+    async def heartbeat(self, job_id):
+        # An empty PATCH refreshes the job's lease so the gateway's reaper
+        # leaves long-running stages alone. A 409 means the job was settled
+        # behind our back, e.g. cancelled while we missed the message.
+        while True:
+            await asyncio.sleep(self.config.heartbeat_interval)
+            if await self.send_update(job_id, {}) == 409:
+                self.abort_job(job_id)
+                return
+
+    async def report_progress(self, job_id, stage, progress):
+        await self.send_update(job_id, {"stage": stage, "progress": progress})
+
+    async def retry_or_dead_letter(self, job_id, message, reason):
+        headers = dict(message.headers or {})
+        retries = int(headers.get("x-retry-count", 0))
+        headers["x-retry-count"] = retries + 1
+        headers["x-failure-reason"] = reason
+
+        if retries < self.config.max_job_retries:
+            status_code = await self.update_job_status(job_id, "retrying", error=reason)
+            exchange, routing_key = self.channel.default_exchange, self.config.rabbitmq_queue
+        else:
+            status_code = await self.update_job_status(job_id, "failed", error=reason)
+            exchange = await self.channel.get_exchange(self.config.rabbitmq_dlx)
+            routing_key = self.config.rabbitmq_queue
+        if status_code == 409:
+            # The job was cancelled meanwhile; there is nothing to retry.
+            return
+
+        await exchange.publish(
+            Message(
+                body=message.body,
+                headers=headers,
+                message_id=message.message_id,
+                content_type=message.content_type,
+                delivery_mode=DeliveryMode.PERSISTENT,
+            ),
+            routing_key=routing_key,
+        )
     
 
 
     async def update_job_status(self, job_id, status, result_url=None, error=None):
-        url = f"{self.config.api_base_url}/api/internal/jobs/{job_id}"  # ✅ Changed path
-        headers = {"X-Internal-API-Key": self.config.internal_api_key}
-
         payload = {"status": status}
         if result_url:
             payload["result_url"] = result_url
         if error:
             payload["error"] = error
         
+        status_code = await self.send_update(job_id, payload)
+        if status_code and status_code < 400:
+            logger.info(f"Updated job {job_id} to {status}")
+        return status_code
+
+    async def send_update(self, job_id, payload):
+        # Returns the gateway's status code, or None if it could not be reached.
+        url = f"{self.config.api_base_url}/api/internal/jobs/{job_id}"
+        headers = {"X-Internal-API-Key": self.config.internal_api_key}
         try:
             response = await self.http_client.patch(url, json=payload, headers=headers)
-            logger.info(f"Updated job {job_id} to {status}")
         except Exception as e:
-            logger.error(f"Failed to update job status: {e}")
\ No newline at end of file
+            logger.error(f"Failed to update job {job_id}: {e}")
+            return None
+        if response.is_error:
+            logger.error(f"Failed to update job {job_id}: {response.status_code} {response.text}")
+        return response.status_code
\ No newline at end of file
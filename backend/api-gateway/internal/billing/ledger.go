@@ -0,0 +1,102 @@
+// Package billing owns every change to a user's credit balance. Each change
+// is written together with a models.Transaction row inside the caller's
+// database transaction, so balances and the ledger cannot drift apart.
+package billing
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+var (
+	ErrUserNotFound        = errors.New("billing: user not found")
+	ErrInsufficientCredits = errors.New("billing: insufficient credits")
+)
+
+// Credit adds amount to the user's balance and records it under
+// transactionID. If a ledger row with that ID already exists nothing is
+// changed and applied is false, which makes retries safe.
+func Credit(tx *gorm.DB, userID uuid.UUID, amount float64, transactionID, source string) (txn *models.Transaction, applied bool, err error) {
+	return post(tx, userID, amount, transactionID, source)
+}
+
+// post applies a signed change to the user's balance. Debits that would take
+// the balance below zero fail with ErrInsufficientCredits.
+func post(tx *gorm.DB, userID uuid.UUID, amount float64, transactionID, source string) (*models.Transaction, bool, error) {
+	var existing models.Transaction
+	err := tx.Where("transaction_id = ?", transactionID).First(&existing).Error
+	if err == nil {
+		return &existing, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, false, ErrUserNotFound
+		}
+		return nil, false, err
+	}
+	if amount < 0 && user.Credits+amount < 0 {
+		return nil, false, ErrInsufficientCredits
+	}
+
+	prevCredits := user.Credits
+	user.Credits += amount
+	if err := tx.Model(&user).Update("credits", user.Credits).Error; err != nil {
+		return nil, false, err
+	}
+
+	txn := &models.Transaction{
+		ID:             uuid.New(),
+		UserID:         userID,
+		Amount:         amount,
+		TransactionID:  transactionID,
+		Source:         source,
+		PreviousAmount: prevCredits,
+		NewAmount:      user.Credits,
+		CreatedAt:      time.Now(),
+	}
+	if err := tx.Create(txn).Error; err != nil {
+		return nil, false, err
+	}
+	return txn, true, nil
+}
+
+// HoldTransactionID and ReleaseTransactionID are the ledger keys for a job's
+// reservation. A job replayed after it was refunded is held again, and each
+// such round gets a numbered pair of keys; the first round is unnumbered.
+func HoldTransactionID(jobID uuid.UUID, round int) string {
+	return roundKey("hold:"+jobID.String(), round)
+}
+
+func ReleaseTransactionID(jobID uuid.UUID, round int) string {
+	return roundKey("release:"+jobID.String(), round)
+}
+
+func roundKey(key string, round int) string {
+	if round <= 1 {
+		return key
+	}
+	return key + ":" + strconv.Itoa(round)
+}
+
+// holdRound counts the holds posted for a job, which is the round its
+// current reservation belongs to.
+func holdRound(tx *gorm.DB, jobID uuid.UUID) (int, error) {
+	first := HoldTransactionID(jobID, 1)
+	var n int64
+	err := tx.Model(&models.Transaction{}).
+		Where("transaction_id = ? OR transaction_id LIKE ?", first, first+":%").
+		Count(&n).Error
+	return int(n), err
+}
@@ -0,0 +1,26 @@
+package billing
+
+import (
+	"math"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+// RefundPolicy decides how much of a failed job's cost goes back to the
+// user. Jobs that never reached a worker are refunded in full; once a worker
+// has started, only ProcessingRatio of the cost is returned.
+type RefundPolicy struct {
+	ProcessingRatio float64
+}
+
+// Amount is the refund owed for job failing while it was in status from.
+func (p RefundPolicy) Amount(job *models.Job, from string) float64 {
+	switch from {
+	case models.JobStatusFetching, models.JobStatusPending:
+		return job.Cost
+	case models.JobStatusProcessing, models.JobStatusRetrying:
+		ratio := math.Min(math.Max(p.ProcessingRatio, 0), 1)
+		return math.Round(job.Cost*ratio*1e4) / 1e4
+	}
+	return 0
+}
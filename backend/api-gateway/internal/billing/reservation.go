@@ -0,0 +1,118 @@
+package billing
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+// Hold takes job.Cost out of the owner's balance and records a reservation
+// for it. Call it in the same transaction that inserts or prices the job so
+// a job can never exist in a payable state without its credits.
+func Hold(tx *gorm.DB, job *models.Job) error {
+	if _, _, err := post(tx, job.UserID, -job.Cost, HoldTransactionID(job.ID, 1), "job_hold"); err != nil {
+		return err
+	}
+	now := time.Now()
+	return tx.Create(&models.CreditReservation{
+		ID:        uuid.New(),
+		JobID:     job.ID,
+		UserID:    job.UserID,
+		Amount:    job.Cost,
+		Status:    models.ReservationHeld,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}).Error
+}
+
+// Rehold charges a job again after its reservation was settled, for a job
+// an operator replays. A job whose credits are still held is left as is.
+func Rehold(tx *gorm.DB, job *models.Job) error {
+	var res models.CreditReservation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("job_id = ?", job.ID).First(&res).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Hold(tx, job)
+	}
+	if err != nil {
+		return err
+	}
+	if res.Status == models.ReservationHeld {
+		return nil
+	}
+
+	round, err := holdRound(tx, job.ID)
+	if err != nil {
+		return err
+	}
+	if _, _, err := post(tx, job.UserID, -job.Cost, HoldTransactionID(job.ID, round+1), "job_hold"); err != nil {
+		return err
+	}
+	res.Amount = job.Cost
+	res.Released = 0
+	res.Status = models.ReservationHeld
+	res.UpdatedAt = time.Now()
+	if err := tx.Save(&res).Error; err != nil {
+		return err
+	}
+
+	job.Refunded = 0
+	return tx.Model(&models.Job{}).Where("id = ?", job.ID).Update("refunded", 0).Error
+}
+
+// Capture turns a job's hold into a final charge. The balance was already
+// debited by Hold, so only the reservation changes.
+func Capture(tx *gorm.DB, jobID uuid.UUID) (bool, error) {
+	result := tx.Model(&models.CreditReservation{}).
+		Where("job_id = ? AND status = ?", jobID, models.ReservationHeld).
+		Updates(map[string]interface{}{
+			"status":     models.ReservationCaptured,
+			"updated_at": time.Now(),
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Release returns amount of a job's hold to its owner and closes the
+// reservation; anything not returned counts as captured. Only a held
+// reservation can be released, so repeated failure or cancel callbacks
+// are no-ops.
+func Release(tx *gorm.DB, job *models.Job, amount float64, source string) (bool, error) {
+	var res models.CreditReservation
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("job_id = ?", job.ID).First(&res).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if res.Status != models.ReservationHeld {
+		return false, nil
+	}
+
+	if amount > res.Amount {
+		amount = res.Amount
+	}
+	if amount > 0 {
+		round, err := holdRound(tx, job.ID)
+		if err != nil {
+			return false, err
+		}
+		if _, _, err := post(tx, job.UserID, amount, ReleaseTransactionID(job.ID, round), source); err != nil {
+			return false, err
+		}
+	}
+
+	res.Status = models.ReservationReleased
+	res.Released = amount
+	res.UpdatedAt = time.Now()
+	if err := tx.Save(&res).Error; err != nil {
+		return false, err
+	}
+
+	job.Refunded = amount
+	return true, tx.Model(&models.Job{}).Where("id = ?", job.ID).Update("refunded", amount).Error
+}
@@ -5,7 +5,6 @@ import (
 	"time"
 
 	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
-	"github.com/rabbitmq/amqp091-go"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -22,7 +21,7 @@ func InitDB(url string) (*gorm.DB, error) {
 	sqlDB.SetMaxIdleConns(5)
 	sqlDB.SetConnMaxLifetime(5 * time.Minute)
 
-	db.AutoMigrate(&models.User{}, &models.Job{}, &models.Transaction{})
+	db.AutoMigrate(&models.User{}, &models.Job{}, &models.Transaction{}, &models.CreditReservation{}, &models.OutboxMessage{}, &models.Batch{}, &models.RecoveryCode{}, &models.UserIdentity{})
 
 	return db, nil
 }
@@ -34,20 +33,3 @@ func InitRedis(url string) (*redis.Client, error) {
 	_, err := client.Ping(ctx).Result()
 	return client, err
 }
-
-func InitRabbitMQ(url, queue, dlq string) (*amqp091.Connection, *amqp091.Channel, error) {
-	conn, err := amqp091.Dial(url)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	ch, err := conn.Channel()
-	if err != nil {
-		return nil, nil, err
-	}
-
-	ch.QueueDeclare(queue, true, false, false, false, nil)
-	ch.QueueDeclare(dlq, true, false, false, false, nil)
-
-	return conn, ch, nil
-}
@@ -0,0 +1,4 @@
+ALTER TABLE jobs ADD COLUMN source_file_name TEXT;
+ALTER TABLE jobs ADD COLUMN source_file_size BIGINT;
+ALTER TABLE jobs ADD COLUMN source_checksum VARCHAR(64);
+ALTER TABLE jobs ADD COLUMN source_mime_type VARCHAR(255);
@@ -0,0 +1,2 @@
+ALTER TABLE jobs ADD COLUMN claimed_duration BIGINT;
+ALTER TABLE jobs ADD COLUMN duration_flagged BOOLEAN DEFAULT FALSE;
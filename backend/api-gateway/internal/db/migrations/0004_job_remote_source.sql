@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN source_remote_url TEXT;
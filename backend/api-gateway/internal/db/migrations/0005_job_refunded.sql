@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN refunded DECIMAL(10,4) DEFAULT 0;
@@ -0,0 +1,12 @@
+CREATE TABLE credit_reservations (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	job_id UUID NOT NULL UNIQUE REFERENCES jobs(id),
+	user_id UUID NOT NULL REFERENCES users(id),
+	amount DECIMAL(10,4) NOT NULL,
+	released DECIMAL(10,4) DEFAULT 0,
+	status VARCHAR(20) NOT NULL DEFAULT 'held',
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_credit_reservations_user_id ON credit_reservations(user_id);
@@ -0,0 +1,15 @@
+CREATE TABLE outbox_messages (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	exchange VARCHAR(255) NOT NULL DEFAULT '',
+	routing_key VARCHAR(255) NOT NULL,
+	message_id VARCHAR(255) NOT NULL,
+	content_type VARCHAR(100),
+	payload BYTEA NOT NULL,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT,
+	next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	sent_at TIMESTAMP,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_outbox_messages_next_attempt_at ON outbox_messages(next_attempt_at) WHERE sent_at IS NULL;
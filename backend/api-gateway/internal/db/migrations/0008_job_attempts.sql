@@ -0,0 +1 @@
+ALTER TABLE jobs ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,4 @@
+ALTER TABLE jobs ADD COLUMN stage VARCHAR(20);
+ALTER TABLE jobs ADD COLUMN progress INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN eta TIMESTAMP;
+ALTER TABLE jobs ADD COLUMN heartbeat_at TIMESTAMP;
@@ -0,0 +1,3 @@
+ALTER TABLE jobs ADD COLUMN kind VARCHAR(32) NOT NULL DEFAULT 'audio_translation';
+ALTER TABLE jobs ADD COLUMN options JSONB NOT NULL DEFAULT '{}';
+CREATE INDEX idx_jobs_kind ON jobs(kind);
@@ -0,0 +1,3 @@
+ALTER TABLE jobs ADD COLUMN parent_id UUID REFERENCES jobs(id);
+ALTER TABLE jobs ADD COLUMN child_count INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX idx_jobs_parent_id ON jobs(parent_id);
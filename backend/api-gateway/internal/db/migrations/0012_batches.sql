@@ -0,0 +1,13 @@
+CREATE TABLE batches (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id UUID NOT NULL REFERENCES users(id),
+	job_count INTEGER NOT NULL DEFAULT 0,
+	cost DECIMAL(10,4) DEFAULT 0,
+	created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_batches_user_id ON batches(user_id);
+
+ALTER TABLE jobs ADD COLUMN batch_id UUID REFERENCES batches(id);
+CREATE INDEX idx_jobs_batch_id ON jobs(batch_id);
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN status VARCHAR(32) NOT NULL DEFAULT 'active';
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMP;
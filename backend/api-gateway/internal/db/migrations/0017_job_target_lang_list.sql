@@ -0,0 +1 @@
+ALTER TABLE jobs ALTER COLUMN target_lang TYPE TEXT;
@@ -0,0 +1,359 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+var (
+	ErrBrokerUnavailable = errors.New("rabbitmq: broker unavailable")
+	ErrBrokerClosed      = errors.New("rabbitmq: broker closed")
+	ErrNacked            = errors.New("rabbitmq: message was not confirmed")
+)
+
+// Topology declares exchanges and queues. It runs on every (re)connect, so
+// it must be idempotent.
+type Topology func(ch *amqp091.Channel) error
+
+// Header names used on job messages.
+const (
+	HeaderRetryCount    = "x-retry-count"
+	HeaderFailureReason = "x-failure-reason"
+)
+
+// JobTopology is the topology the gateway and workers share. Messages a
+// worker rejects, or gives up on after its retries, are routed through dlx
+// to dlq. Queue arguments cannot be changed on an existing queue, so the
+// dead-letter arguments came with a new queue name; messages still waiting
+// in legacyQueue are moved over and the old queue is then deleted.
+func JobTopology(queue, legacyQueue, dlq, dlx, cancelExchange string) Topology {
+	return func(ch *amqp091.Channel) error {
+		if err := ch.ExchangeDeclare(dlx, amqp091.ExchangeDirect, true, false, false, false, nil); err != nil {
+			return err
+		}
+		if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+			return err
+		}
+		if err := ch.QueueBind(dlq, queue, dlx, false, nil); err != nil {
+			return err
+		}
+		if _, err := ch.QueueDeclare(queue, true, false, false, false, amqp091.Table{
+			"x-dead-letter-exchange":    dlx,
+			"x-dead-letter-routing-key": queue,
+		}); err != nil {
+			return err
+		}
+		// Workers bind their own exclusive queue to this exchange and drop
+		// work for any job ID announced on it.
+		if err := ch.ExchangeDeclare(cancelExchange, amqp091.ExchangeFanout, true, false, false, false, nil); err != nil {
+			return err
+		}
+		if legacyQueue == "" || legacyQueue == queue {
+			return nil
+		}
+		// Last, because a missing queue closes the channel.
+		return migrateQueue(ch, legacyQueue, queue)
+	}
+}
+
+// migrateQueue moves every message from one queue to another, each one
+// confirmed before it is acked, and deletes the emptied queue. It is a no-op
+// once the old queue is gone.
+func migrateQueue(ch *amqp091.Channel, from, to string) error {
+	if _, err := ch.QueueDeclarePassive(from, true, false, false, false, nil); err != nil {
+		if isAMQPCode(err, amqp091.NotFound) {
+			return nil
+		}
+		return err
+	}
+	if err := ch.Confirm(false); err != nil {
+		return err
+	}
+
+	moved := 0
+	for {
+		d, ok, err := ch.Get(from, false)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		conf, err := ch.PublishWithDeferredConfirmWithContext(ctx, "", to, false, false, amqp091.Publishing{
+			Headers:         d.Headers,
+			ContentType:     d.ContentType,
+			ContentEncoding: d.ContentEncoding,
+			DeliveryMode:    amqp091.Persistent,
+			Priority:        d.Priority,
+			CorrelationId:   d.CorrelationId,
+			MessageId:       d.MessageId,
+			Timestamp:       d.Timestamp,
+			Type:            d.Type,
+			AppId:           d.AppId,
+			Body:            d.Body,
+		})
+		if err == nil {
+			var acked bool
+			acked, err = conf.WaitContext(ctx)
+			if err == nil && !acked {
+				err = ErrNacked
+			}
+		}
+		cancel()
+		if err != nil {
+			// Unacked, so the broker puts it back in the old queue.
+			return err
+		}
+		if err := d.Ack(false); err != nil {
+			return err
+		}
+		moved++
+	}
+	log.Printf("rabbitmq: moved %d messages from %s to %s", moved, from, to)
+
+	if _, err := ch.QueueDelete(from, false, true, false); err != nil {
+		if isAMQPCode(err, amqp091.PreconditionFailed) {
+			// A publisher still on the old name got a message in; the next
+			// connect moves it.
+			log.Printf("rabbitmq: %s is not empty yet, keeping it", from)
+			return nil
+		}
+		return err
+	}
+	log.Printf("rabbitmq: deleted legacy queue %s", from)
+	return nil
+}
+
+func isAMQPCode(err error, code int) bool {
+	var amqpErr *amqp091.Error
+	return errors.As(err, &amqpErr) && amqpErr.Code == code
+}
+
+// Broker owns the RabbitMQ connection. It redials with backoff whenever the
+// connection drops, re-declares the topology and hands out confirm-mode
+// channels from a small pool, since an amqp091.Channel must not be shared
+// between goroutines.
+type Broker struct {
+	url      string
+	topology Topology
+
+	mu      sync.Mutex
+	conn    *amqp091.Connection
+	idle    []*amqp091.Channel
+	lastErr error
+
+	slots  chan struct{}
+	closed chan struct{}
+	once   sync.Once
+}
+
+// InitRabbitMQ connects to url and applies topology. The first connection
+// must succeed; later outages are healed in the background.
+func InitRabbitMQ(url string, topology Topology, poolSize int) (*Broker, error) {
+	if poolSize < 1 {
+		poolSize = 1
+	}
+	b := &Broker{
+		url:      url,
+		topology: topology,
+		slots:    make(chan struct{}, poolSize),
+		closed:   make(chan struct{}),
+	}
+	conn, err := b.dial()
+	if err != nil {
+		return nil, err
+	}
+	b.conn = conn
+	go b.watch(conn)
+	return b, nil
+}
+
+func (b *Broker) dial() (*amqp091.Connection, error) {
+	conn, err := amqp091.Dial(b.url)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	defer ch.Close()
+	if err := b.topology(ch); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// watch waits for conn to close and then redials until it succeeds or the
+// broker is closed.
+func (b *Broker) watch(conn *amqp091.Connection) {
+	for {
+		select {
+		case <-b.closed:
+			return
+		case amqpErr := <-conn.NotifyClose(make(chan *amqp091.Error, 1)):
+			var err error = ErrBrokerUnavailable
+			if amqpErr != nil {
+				err = amqpErr
+			}
+			log.Printf("rabbitmq: connection lost: %v", err)
+			b.mu.Lock()
+			b.conn = nil
+			b.lastErr = err
+			b.idle = nil
+			b.mu.Unlock()
+		}
+
+		backoff := time.Second
+		for {
+			select {
+			case <-b.closed:
+				return
+			case <-time.After(backoff):
+			}
+			next, err := b.dial()
+			if err == nil {
+				conn = next
+				break
+			}
+			log.Printf("rabbitmq: reconnect failed: %v", err)
+			b.mu.Lock()
+			b.lastErr = err
+			b.mu.Unlock()
+			backoff = min(backoff*2, 30*time.Second)
+		}
+
+		b.mu.Lock()
+		b.conn = conn
+		b.lastErr = nil
+		b.mu.Unlock()
+		log.Println("rabbitmq: reconnected")
+	}
+}
+
+// Healthy returns nil while the broker is connected, otherwise the reason
+// it is not.
+func (b *Broker) Healthy() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	select {
+	case <-b.closed:
+		return ErrBrokerClosed
+	default:
+	}
+	if b.conn == nil || b.conn.IsClosed() {
+		if b.lastErr != nil {
+			return b.lastErr
+		}
+		return ErrBrokerUnavailable
+	}
+	return nil
+}
+
+// Publish sends msg and waits for the broker to confirm it.
+func (b *Broker) Publish(ctx context.Context, exchange, key string, msg amqp091.Publishing) error {
+	ch, err := b.acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	conf, err := ch.PublishWithDeferredConfirmWithContext(ctx, exchange, key, false, false, msg)
+	if err != nil {
+		b.release(ch, false)
+		return err
+	}
+	acked, err := conf.WaitContext(ctx)
+	if err != nil {
+		// A confirm that never arrived leaves the channel in an unknown
+		// state; do not hand it out again.
+		b.release(ch, false)
+		return err
+	}
+	b.release(ch, true)
+	if !acked {
+		return ErrNacked
+	}
+	return nil
+}
+
+// Channel opens a plain channel on the current connection for callers that
+// consume or inspect queues. The caller must close it.
+func (b *Broker) Channel() (*amqp091.Channel, error) {
+	b.mu.Lock()
+	conn := b.conn
+	b.mu.Unlock()
+	if conn == nil || conn.IsClosed() {
+		return nil, ErrBrokerUnavailable
+	}
+	return conn.Channel()
+}
+
+func (b *Broker) acquire(ctx context.Context) (*amqp091.Channel, error) {
+	select {
+	case b.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-b.closed:
+		return nil, ErrBrokerClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for len(b.idle) > 0 {
+		ch := b.idle[len(b.idle)-1]
+		b.idle = b.idle[:len(b.idle)-1]
+		if !ch.IsClosed() {
+			return ch, nil
+		}
+	}
+
+	if b.conn == nil || b.conn.IsClosed() {
+		<-b.slots
+		return nil, ErrBrokerUnavailable
+	}
+	ch, err := b.conn.Channel()
+	if err == nil {
+		err = ch.Confirm(false)
+	}
+	if err != nil {
+		if ch != nil {
+			ch.Close()
+		}
+		<-b.slots
+		return nil, err
+	}
+	return ch, nil
+}
+
+func (b *Broker) release(ch *amqp091.Channel, reusable bool) {
+	b.mu.Lock()
+	if reusable && !ch.IsClosed() {
+		b.idle = append(b.idle, ch)
+	} else {
+		ch.Close()
+	}
+	b.mu.Unlock()
+	<-b.slots
+}
+
+func (b *Broker) Close() error {
+	b.once.Do(func() { close(b.closed) })
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.idle {
+		ch.Close()
+	}
+	b.idle = nil
+	if b.conn == nil {
+		return nil
+	}
+	return b.conn.Close()
+}
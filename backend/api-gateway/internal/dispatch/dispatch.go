@@ -0,0 +1,82 @@
+// Package dispatch builds the message a worker receives for a job and puts
+// it on the outbox. Everything that (re)queues a job goes through here so the
+// message format lives in one place.
+package dispatch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/jobkind"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/outbox"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/storage"
+)
+
+// sourceURLExpiry bounds how long a worker has to start downloading a
+// source. The result upload URL is given the same time to finish.
+const sourceURLExpiry = 24 * time.Hour
+
+type Dispatcher struct {
+	storage storage.Storage
+	queue   string
+}
+
+func New(store storage.Storage, queue string) *Dispatcher {
+	return &Dispatcher{storage: store, queue: queue}
+}
+
+// Enqueue writes the worker message for job to the outbox in tx.
+func (d *Dispatcher) Enqueue(ctx context.Context, tx *gorm.DB, job *models.Job) error {
+	jobMsg := map[string]interface{}{
+		"job_id":      job.ID.String(),
+		"kind":        job.Kind,
+		"source_file": job.SourceFileURL,
+		"source_lang": job.SourceLang,
+		"target_lang": job.TargetLang,
+		"duration":    job.Duration,
+		"user_id":     job.UserID.String(),
+	}
+	// Children of one multi-language job share a source; workers key a
+	// shared transcription on parent_id.
+	if job.ParentID != nil {
+		jobMsg["parent_id"] = job.ParentID.String()
+	}
+	if len(job.Options) > 0 {
+		jobMsg["options"] = job.Options
+	}
+	if signed, err := d.storage.SignedURL(ctx, http.MethodGet, job.SourceFileURL, sourceURLExpiry); err == nil {
+		jobMsg["source_url"] = signed
+	}
+	// Workers upload what they produce through the store rather than to
+	// their own disk, and report result_key back as the job's result_url.
+	resultKey := storage.ResultKey(job.UserID.String(), job.ID.String(), resultFormat(job))
+	if signed, err := d.storage.SignedURL(ctx, http.MethodPut, resultKey, sourceURLExpiry); err == nil {
+		jobMsg["result_key"] = resultKey
+		jobMsg["result_upload_url"] = signed
+	}
+	if job.SourceChecksum != "" {
+		jobMsg["source_size"] = job.SourceFileSize
+		jobMsg["source_checksum"] = job.SourceChecksum
+		jobMsg["source_mime_type"] = job.SourceMimeType
+	}
+
+	return outbox.Enqueue(tx, "", d.queue, job.ID.String(), jobMsg)
+}
+
+// resultFormat is the file extension of what job produces.
+func resultFormat(job *models.Job) string {
+	var opts jobkind.Options
+	json.Unmarshal(job.Options, &opts)
+	switch {
+	case opts.OutputFormat != "":
+		return opts.OutputFormat
+	case opts.SubtitleFormat != "":
+		return opts.SubtitleFormat
+	}
+	return "wav"
+}
@@ -0,0 +1,140 @@
+// Package dlq reads the dead-letter queue. AMQP has no random access, so
+// every operation walks the queue with basic.get and leaves the messages it
+// does not remove unacknowledged until the channel closes, which puts them
+// back in place.
+package dlq
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/db"
+)
+
+// Message is a dead-lettered job message with the failure details pulled
+// out of its headers.
+type Message struct {
+	MessageID     string          `json:"message_id"`
+	JobID         string          `json:"job_id"`
+	RetryCount    int64           `json:"retry_count"`
+	FailureReason string          `json:"failure_reason,omitempty"`
+	DeathReason   string          `json:"death_reason,omitempty"`
+	DeathCount    int64           `json:"death_count"`
+	Timestamp     time.Time       `json:"timestamp"`
+	Body          json.RawMessage `json:"body"`
+}
+
+type Manager struct {
+	broker *db.Broker
+	dlq    string
+}
+
+func New(broker *db.Broker, dlq string) *Manager {
+	return &Manager{broker: broker, dlq: dlq}
+}
+
+// Walk visits up to limit messages (the whole queue if limit is 0). When fn
+// returns true the message is acknowledged and leaves the queue. An error
+// from fn stops the walk; messages already removed stay removed.
+func (m *Manager) Walk(ctx context.Context, limit int, fn func(*Message) (bool, error)) (int, error) {
+	ch, err := m.broker.Channel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueDeclarePassive(m.dlq, true, false, false, false, nil)
+	if err != nil {
+		return 0, err
+	}
+	depth := q.Messages
+	if limit <= 0 || limit > depth {
+		limit = depth
+	}
+
+	for i := 0; i < limit; i++ {
+		if err := ctx.Err(); err != nil {
+			return depth, err
+		}
+		d, ok, err := ch.Get(m.dlq, false)
+		if err != nil {
+			return depth, err
+		}
+		if !ok {
+			break
+		}
+
+		remove, err := fn(decode(&d))
+		if err != nil {
+			return depth, err
+		}
+		if remove {
+			if err := d.Ack(false); err != nil {
+				return depth, err
+			}
+		}
+	}
+	return depth, nil
+}
+
+func decode(d *amqp091.Delivery) *Message {
+	msg := &Message{
+		MessageID: d.MessageId,
+		Timestamp: d.Timestamp,
+		Body:      json.RawMessage(d.Body),
+	}
+
+	var body struct {
+		JobID string `json:"job_id"`
+	}
+	if json.Unmarshal(d.Body, &body) == nil {
+		msg.JobID = body.JobID
+	} else {
+		msg.Body, _ = json.Marshal(string(d.Body))
+	}
+	if msg.MessageID == "" {
+		msg.MessageID = msg.JobID
+	}
+
+	msg.RetryCount = headerInt(d.Headers[db.HeaderRetryCount])
+	if reason, ok := d.Headers[db.HeaderFailureReason].(string); ok {
+		msg.FailureReason = reason
+	}
+	// RabbitMQ records each dead-lettering in x-death, newest first.
+	if deaths, ok := d.Headers["x-death"].([]interface{}); ok && len(deaths) > 0 {
+		if death, ok := deaths[0].(amqp091.Table); ok {
+			if reason, ok := death["reason"].(string); ok {
+				msg.DeathReason = reason
+			}
+			msg.DeathCount = headerInt(death["count"])
+		}
+	}
+	return msg
+}
+
+func headerInt(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int8:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case uint8:
+		return int64(n)
+	case uint16:
+		return int64(n)
+	case uint32:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
@@ -0,0 +1,110 @@
+// Package events fans job updates out to every gateway replica over Redis
+// pub/sub, so a client streaming progress from one replica sees callbacks
+// that reached another.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+// JobEvent is what streaming clients receive for every change to a job.
+type JobEvent struct {
+	JobID     string     `json:"job_id"`
+	Status    string     `json:"status"`
+	Stage     string     `json:"stage,omitempty"`
+	Progress  int        `json:"progress"`
+	ETA       *time.Time `json:"eta,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+func NewJobEvent(job *models.Job) *JobEvent {
+	return &JobEvent{
+		JobID:     job.ID.String(),
+		Status:    job.Status,
+		Stage:     job.Stage,
+		Progress:  job.Progress,
+		ETA:       job.ETA,
+		Error:     job.Error,
+		UpdatedAt: job.UpdatedAt,
+	}
+}
+
+// Final reports whether no further events will follow this one.
+func (e *JobEvent) Final() bool {
+	return models.IsTerminal(e.Status)
+}
+
+type Bus struct {
+	redis *redis.Client
+}
+
+func NewBus(client *redis.Client) *Bus {
+	return &Bus{redis: client}
+}
+
+func channel(jobID uuid.UUID) string {
+	return "jobs:events:" + jobID.String()
+}
+
+// PublishJob announces the current state of job. Delivery is best effort;
+// subscribers that miss an event still get the next one.
+func (b *Bus) PublishJob(ctx context.Context, job *models.Job) error {
+	data, err := json.Marshal(NewJobEvent(job))
+	if err != nil {
+		return err
+	}
+	return b.redis.Publish(ctx, channel(job.ID), data).Err()
+}
+
+// Subscription delivers events for a single job until Close is called.
+type Subscription struct {
+	pubsub *redis.PubSub
+	events chan *JobEvent
+	done   chan struct{}
+	once   sync.Once
+}
+
+// SubscribeJob starts listening for jobID. It returns once Redis has
+// confirmed the subscription, so nothing published afterwards is missed.
+func (b *Bus) SubscribeJob(ctx context.Context, jobID uuid.UUID) (*Subscription, error) {
+	pubsub := b.redis.Subscribe(ctx, channel(jobID))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+
+	sub := &Subscription{pubsub: pubsub, events: make(chan *JobEvent, 16), done: make(chan struct{})}
+	go func() {
+		defer close(sub.events)
+		for msg := range pubsub.Channel() {
+			var event JobEvent
+			if json.Unmarshal([]byte(msg.Payload), &event) != nil {
+				continue
+			}
+			select {
+			case sub.events <- &event:
+			case <-sub.done:
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
+func (s *Subscription) Events() <-chan *JobEvent {
+	return s.events
+}
+
+func (s *Subscription) Close() error {
+	s.once.Do(func() { close(s.done) })
+	return s.pubsub.Close()
+}
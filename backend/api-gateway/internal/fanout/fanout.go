@@ -0,0 +1,57 @@
+// Package fanout keeps multi-language parent jobs in step with their
+// children. Children are the jobs workers run; a parent only mirrors them.
+package fanout
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+// Refresh recomputes the status, progress and refund total of child's
+// parent in tx and returns the updated parent, or nil if child has none.
+// Call it after the child's own change has been written in the same tx.
+//
+// The parent row is locked before the children are read, so of two children
+// changing concurrently the second to commit always sees the first.
+func Refresh(tx *gorm.DB, child *models.Job) (*models.Job, error) {
+	if child.ParentID == nil {
+		return nil, nil
+	}
+
+	var parent models.Job
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&parent, "id = ?", *child.ParentID).Error; err != nil {
+		return nil, err
+	}
+
+	var children []models.Job
+	if err := tx.Select("status", "progress", "refunded").Where("parent_id = ?", parent.ID).Find(&children).Error; err != nil {
+		return nil, err
+	}
+	if len(children) == 0 {
+		return &parent, nil
+	}
+
+	statuses := make([]string, len(children))
+	progress, refunded := 0, 0.0
+	for i, c := range children {
+		statuses[i] = c.Status
+		progress += c.Progress
+		refunded += c.Refunded
+	}
+
+	parent.Status = models.AggregateStatus(statuses)
+	parent.Progress = progress / len(children)
+	parent.Refunded = refunded
+	parent.UpdatedAt = time.Now()
+	err := tx.Model(&parent).Updates(map[string]interface{}{
+		"status":     parent.Status,
+		"progress":   parent.Progress,
+		"refunded":   parent.Refunded,
+		"updated_at": parent.UpdatedAt,
+	}).Error
+	return &parent, err
+}
@@ -0,0 +1,256 @@
+// Package fetcher downloads remote media referenced by source_file_url into
+// storage, refusing to talk to internal addresses.
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/media"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/storage"
+)
+
+var (
+	ErrInvalidURL       = errors.New("fetcher: only absolute http(s) URLs are allowed")
+	ErrBlockedAddress   = errors.New("fetcher: destination address is not allowed")
+	ErrTooManyRedirects = errors.New("fetcher: too many redirects")
+	ErrTooLarge         = errors.New("fetcher: remote file exceeds maximum size")
+	ErrNotMedia         = errors.New("fetcher: remote file is not audio or video")
+)
+
+// blockedPrefixes covers ranges that netip's Is* helpers do not.
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+type Options struct {
+	MaxSize      int64
+	Timeout      time.Duration
+	MaxRedirects int
+	// AllowPrivate disables address filtering. Only for local development
+	// and tests against an httptest server.
+	AllowPrivate bool
+}
+
+type Result struct {
+	Key      string
+	Size     int64
+	Checksum string
+	MimeType string
+}
+
+type Fetcher struct {
+	client  *http.Client
+	storage storage.Storage
+	opts    Options
+}
+
+func New(store storage.Storage, opts Options) *Fetcher {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+	}
+	if !opts.AllowPrivate {
+		// Checking at dial time covers redirects and DNS rebinding: the
+		// address validated is the one actually connected to.
+		dialer.Control = checkDial
+	}
+
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > opts.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			return validateURL(req.URL, opts.AllowPrivate)
+		},
+	}
+
+	return &Fetcher{client: client, storage: store, opts: opts}
+}
+
+// Validate rejects URLs that can be ruled out without a network round trip.
+func (f *Fetcher) Validate(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ErrInvalidURL
+	}
+	return validateURL(u, f.opts.AllowPrivate)
+}
+
+// Fetch downloads raw into storage under key. The body is sniffed before it
+// is stored so HTML error pages and the like never reach a worker.
+func (f *Fetcher) Fetch(ctx context.Context, raw, key string) (*Result, error) {
+	if err := f.Validate(raw); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.opts.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, raw, nil)
+	if err != nil {
+		return nil, ErrInvalidURL
+	}
+	req.Header.Set("User-Agent", "Octavia-Fetcher/1.0")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		if errors.Is(err, ErrBlockedAddress) || errors.Is(err, ErrTooManyRedirects) || errors.Is(err, ErrInvalidURL) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("fetcher: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetcher: remote returned %s", resp.Status)
+	}
+	if resp.ContentLength > f.opts.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(resp.Body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("fetcher: read failed: %w", err)
+	}
+	head = head[:n]
+
+	mimeType, ok := sniff(head, resp.Header.Get("Content-Type"))
+	if !ok {
+		return nil, ErrNotMedia
+	}
+
+	hasher := sha256.New()
+	body := &limitedReader{r: io.MultiReader(bytes.NewReader(head), resp.Body), n: f.opts.MaxSize}
+	obj, err := f.storage.Put(ctx, key, io.TeeReader(body, hasher), -1, mimeType)
+	if err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			return nil, ErrTooLarge
+		}
+		return nil, fmt.Errorf("fetcher: store failed: %w", err)
+	}
+
+	return &Result{
+		Key:      obj.Key,
+		Size:     obj.Size,
+		Checksum: hex.EncodeToString(hasher.Sum(nil)),
+		MimeType: mimeType,
+	}, nil
+}
+
+func checkDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !allowed(addr) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+func validateURL(u *url.URL, allowPrivate bool) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ErrInvalidURL
+	}
+	if u.Hostname() == "" || u.User != nil {
+		return ErrInvalidURL
+	}
+	if allowPrivate {
+		return nil
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".internal") {
+		return ErrBlockedAddress
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !allowed(addr) {
+		return ErrBlockedAddress
+	}
+	return nil
+}
+
+func allowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+	for _, p := range blockedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// sniff trusts the bytes over the Content-Type header, which many CDNs set
+// to application/octet-stream.
+func sniff(head []byte, declared string) (string, bool) {
+	detected := http.DetectContentType(head)
+	if strings.HasPrefix(detected, "audio/") || strings.HasPrefix(detected, "video/") || detected == "application/ogg" {
+		return detected, true
+	}
+	if _, ok := media.Sniff(head); ok {
+		if strings.HasPrefix(declared, "audio/") || strings.HasPrefix(declared, "video/") {
+			return declared, true
+		}
+		return detected, true
+	}
+	return "", false
+}
+
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
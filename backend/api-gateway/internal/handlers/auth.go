@@ -5,10 +5,16 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/LunarTechAI/octavia/api-gateway/config"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/mailer"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/mfa"
 	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/sso"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/tokens"
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
@@ -20,14 +26,22 @@ import (
 type AuthHandler struct {
 	db        *gorm.DB
 	redis     *redis.Client
+	mailer    mailer.Mailer
+	tokens    *tokens.Store
+	mfa       *mfa.Manager
+	sso       *sso.Registry
 	cfg       *config.Config
 	validator *validator.Validate
 }
 
-func NewAuthHandler(db *gorm.DB, redis *redis.Client, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(db *gorm.DB, redis *redis.Client, mail mailer.Mailer, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
 		db:        db,
 		redis:     redis,
+		mailer:    mail,
+		tokens:    tokens.NewStore(redis, cfg.SessionSecret),
+		mfa:       mfa.New(cfg.MFAIssuer, cfg.MFAEncryptionKey),
+		sso:       sso.NewRegistry(cfg.OIDCProviders, strings.TrimRight(cfg.PublicURL, "/")+"/api/v1/auth/oidc"),
 		cfg:       cfg,
 		validator: validator.New(),
 	}
@@ -69,6 +83,7 @@ func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 		Password:  string(hashedPassword),
 		Name:      req.Name,
 		Credits:   0,
+		Status:    models.UserStatusPendingVerification,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -77,14 +92,24 @@ func (h *AuthHandler) Signup(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create user")
 	}
 
+	// The account exists either way; a lost email can be resent.
+	if err := h.sendVerification(c.Context(), &user, purposeVerifyEmail, user.Email); err != nil {
+		log.Printf("auth: failed to send verification to user %s: %v", user.ID, err)
+	}
+
+	// As with the email, a failed sign-in leaves the account usable.
 	sessionID := generateSessionID()
-	h.setSession(c, sessionID, user.ID)
-	h.setSessionCookie(c, sessionID)
+	if err := h.setSession(c, sessionID, user.ID); err != nil {
+		log.Printf("auth: failed to create session for user %s: %v", user.ID, err)
+	} else {
+		h.setSessionCookie(c, sessionID)
+	}
 
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"id":    user.ID.String(),
-		"email": user.Email,
-		"name":  user.Name,
+		"id":     user.ID.String(),
+		"email":  user.Email,
+		"name":   user.Name,
+		"status": user.Status,
 	})
 }
 
@@ -107,9 +132,21 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
 		return fiber.NewError(fiber.StatusUnauthorized, "Invalid credentials")
 	}
+	if user.Status == models.UserStatusSuspended {
+		return fiber.NewError(fiber.StatusForbidden, "Account suspended")
+	}
+	if user.TOTPEnabledAt != nil {
+		return h.beginMFA(c, &user)
+	}
 
+	return h.completeLogin(c, &user)
+}
+
+func (h *AuthHandler) completeLogin(c *fiber.Ctx, user *models.User) error {
 	sessionID := generateSessionID()
-	h.setSession(c, sessionID, user.ID)
+	if err := h.setSession(c, sessionID, user.ID); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create session")
+	}
 	h.setSessionCookie(c, sessionID)
 
 	return c.JSON(fiber.Map{
@@ -117,6 +154,7 @@ func (h *AuthHandler) Login(c *fiber.Ctx) error {
 		"email":      user.Email,
 		"name":       user.Name,
 		"credits":    user.Credits,
+		"status":     user.Status,
 		"session_id": sessionID,
 	})
 }
@@ -126,19 +164,27 @@ func (h *AuthHandler) Logout(c *fiber.Ctx) error {
 	if sessionID != "" {
 		key := fmt.Sprintf("sess:%s", sessionID)
 		h.redis.Del(c.Context(), key)
+		h.redis.SRem(c.Context(), userSessionsKey(GetUserID(c)), sessionID)
 	}
 	c.ClearCookie(h.cfg.SessionCookieName)
 	return c.JSON(fiber.Map{"success": true})
 }
 
-func (h *AuthHandler) setSession(c *fiber.Ctx, sessionID string, userID uuid.UUID) {
+func (h *AuthHandler) setSession(c *fiber.Ctx, sessionID string, userID uuid.UUID) error {
 	sessionData := map[string]interface{}{
 		"user_id":    userID.String(),
 		"created_at": time.Now().Format(time.RFC3339),
 	}
 	data, _ := json.Marshal(sessionData)
 	key := fmt.Sprintf("sess:%s", sessionID)
-	h.redis.Set(c.Context(), key, data, time.Duration(h.cfg.SessionTTL)*time.Second)
+	ttl := time.Duration(h.cfg.SessionTTL) * time.Second
+	if err := h.redis.Set(c.Context(), key, data, ttl).Err(); err != nil {
+		return err
+	}
+	if err := indexSession(c.Context(), h.redis, userID, sessionID, ttl); err != nil {
+		log.Printf("auth: failed to index session for user %s: %v", userID, err)
+	}
+	return nil
 }
 
 func (h *AuthHandler) setSessionCookie(c *fiber.Ctx, sessionID string) {
@@ -184,10 +230,5 @@ func (h *AuthHandler) GetMe(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, "User not found")
 	}
 
-	return c.JSON(fiber.Map{
-		"id":      user.ID.String(),
-		"email":   user.Email,
-		"name":    user.Name,
-		"credits": user.Credits,
-	})
+	return c.JSON(profileResponse(&user))
 }
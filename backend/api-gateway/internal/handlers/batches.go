@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/billing"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/fanout"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+// BatchJobItem is one source in a batch. Unset fields fall back to the
+// batch's defaults.
+type BatchJobItem struct {
+	SourceFileURL string          `json:"source_file_url"`
+	UploadKey     string          `json:"upload_key"`
+	UploadID      string          `json:"upload_id"`
+	Duration      int64           `json:"duration"`
+	Kind          string          `json:"kind"`
+	Options       json.RawMessage `json:"options"`
+	SourceLang    string          `json:"source_lang"`
+	TargetLang    string          `json:"target_lang"`
+	TargetLangs   []string        `json:"target_langs"`
+}
+
+type BatchJobRequest struct {
+	Defaults BatchJobItem   `json:"defaults"`
+	Items    []BatchJobItem `json:"items"`
+	// Atomic rejects the whole batch if any item is invalid; otherwise
+	// invalid items are reported and the rest are created.
+	Atomic bool `json:"atomic"`
+}
+
+type batchItemResult struct {
+	Index    int       `json:"index"`
+	Created  bool      `json:"created"`
+	Job      fiber.Map `json:"job,omitempty"`
+	Error    string    `json:"error,omitempty"`
+	Code     int       `json:"code,omitempty"`
+	prepared *preparedJob
+}
+
+// jobRequest merges item over defaults.
+func (item *BatchJobItem) jobRequest(defaults *BatchJobItem) *JobRequest {
+	req := &JobRequest{
+		SourceFileURL: item.SourceFileURL,
+		UploadKey:     item.UploadKey,
+		UploadID:      item.UploadID,
+		Duration:      item.Duration,
+		Kind:          firstNonEmpty(item.Kind, defaults.Kind),
+		SourceLang:    firstNonEmpty(item.SourceLang, defaults.SourceLang),
+	}
+	if len(item.Options) > 0 {
+		req.Options = string(item.Options)
+	} else if len(defaults.Options) > 0 {
+		req.Options = string(defaults.Options)
+	}
+	// A target set on the item replaces the default one entirely.
+	switch {
+	case item.TargetLang != "" || len(item.TargetLangs) > 0:
+		req.TargetLang, req.TargetLangs = item.TargetLang, strings.Join(item.TargetLangs, ",")
+	default:
+		req.TargetLang, req.TargetLangs = defaults.TargetLang, strings.Join(defaults.TargetLangs, ",")
+	}
+	return req
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// CreateBatch validates and prices every item, then creates all valid jobs
+// and their credit holds in one transaction: either the user can afford the
+// whole batch or nothing is created. Items with a remote source are neither
+// held nor counted in the batch cost until they are fetched.
+func (h *JobsHandler) CreateBatch(c *fiber.Ctx) error {
+	var req BatchJobRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if len(req.Items) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "items must not be empty")
+	}
+	if len(req.Items) > h.cfg.MaxBatchSize {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("A batch may contain at most %d items", h.cfg.MaxBatchSize))
+	}
+
+	userID := GetUserID(c)
+	results := make([]batchItemResult, len(req.Items))
+	var accepted []*preparedJob
+	for i := range req.Items {
+		results[i].Index = i
+		prepared, err := h.prepareJob(c.Context(), userID, req.Items[i].jobRequest(&req.Defaults))
+		if err != nil {
+			results[i].Code = fiber.StatusInternalServerError
+			results[i].Error = err.Error()
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				results[i].Code = fiberErr.Code
+				results[i].Error = fiberErr.Message
+			}
+			continue
+		}
+		results[i].prepared = prepared
+		accepted = append(accepted, prepared)
+	}
+
+	discardAll := func() {
+		for _, p := range accepted {
+			h.discardUpload(c.Context(), p.upload)
+		}
+	}
+	if len(accepted) == 0 || (req.Atomic && len(accepted) < len(req.Items)) {
+		discardAll()
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":   "No jobs were created",
+			"results": results,
+		})
+	}
+
+	batch := models.Batch{
+		ID:        uuid.New(),
+		UserID:    userID,
+		JobCount:  len(accepted),
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	for _, p := range accepted {
+		p.job.BatchID = &batch.ID
+		for i := range p.work {
+			p.work[i].BatchID = &batch.ID
+		}
+		// Remote sources are held once fetched, so only what is held here
+		// counts toward the batch cost.
+		if p.upload != nil {
+			batch.Cost += p.job.Cost
+		}
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&batch).Error; err != nil {
+			return err
+		}
+		for _, p := range accepted {
+			if err := h.insertJob(c.Context(), tx, p); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		discardAll()
+		if errors.Is(err, billing.ErrInsufficientCredits) {
+			return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Insufficient credits for batch costing %.2f", batch.Cost))
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Batch creation failed")
+	}
+
+	for i := range results {
+		if p := results[i].prepared; p != nil {
+			h.startJob(p)
+			results[i].Created = true
+			results[i].Job = p.summary()
+		}
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"id":        batch.ID.String(),
+		"job_count": batch.JobCount,
+		"cost":      batch.Cost,
+		"results":   results,
+	})
+}
+
+func (h *JobsHandler) GetBatch(c *fiber.Ctx) error {
+	batchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid batch ID")
+	}
+
+	var batch models.Batch
+	if err := h.db.Where("id = ? AND user_id = ?", batchID, GetUserID(c)).First(&batch).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "Batch not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Query failed")
+	}
+
+	if err := h.db.Where("batch_id = ? AND parent_id IS NULL", batch.ID).Order("created_at, id").Find(&batch.Jobs).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Query failed")
+	}
+	statuses := make([]string, len(batch.Jobs))
+	for i := range batch.Jobs {
+		statuses[i] = batch.Jobs[i].Status
+		h.signResultURL(c.Context(), &batch.Jobs[i])
+	}
+	batch.Status = models.AggregateStatus(statuses)
+
+	return c.JSON(batch)
+}
+
+// CancelBatch cancels every unfinished job in the batch, children of
+// multi-language jobs included, refunding each as CancelJob would.
+func (h *JobsHandler) CancelBatch(c *fiber.Ctx) error {
+	batchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid batch ID")
+	}
+	userID := GetUserID(c)
+
+	var cancelled []models.Job
+	parents := map[uuid.UUID]*models.Job{}
+	var refund float64
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var batch models.Batch
+		if err := tx.Where("id = ? AND user_id = ?", batchID, userID).First(&batch).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(fiber.StatusNotFound, "Batch not found")
+			}
+			return err
+		}
+
+		var jobs []models.Job
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("batch_id = ? AND child_count = 0 AND status IN ?", batch.ID, []string{
+				models.JobStatusFetching, models.JobStatusPending, models.JobStatusProcessing, models.JobStatusRetrying,
+			}).
+			Find(&jobs).Error
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			return fiber.NewError(fiber.StatusConflict, "Batch has no jobs left to cancel")
+		}
+
+		for i := range jobs {
+			if err := h.cancelOne(tx, &jobs[i]); err != nil {
+				return err
+			}
+			refund += jobs[i].Refunded
+			parent, err := fanout.Refresh(tx, &jobs[i])
+			if err != nil {
+				return err
+			}
+			if parent != nil {
+				parents[parent.ID] = parent
+			}
+		}
+		cancelled = jobs
+		return nil
+	})
+	if err != nil {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return fiberErr
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to cancel batch")
+	}
+
+	ids := make([]string, len(cancelled))
+	for i := range cancelled {
+		ids[i] = cancelled[i].ID.String()
+		h.publishEvent(c.Context(), &cancelled[i])
+	}
+	for _, parent := range parents {
+		h.publishEvent(c.Context(), parent)
+	}
+
+	return c.JSON(fiber.Map{
+		"id":        batchID.String(),
+		"cancelled": ids,
+		"refunded":  refund,
+	})
+}
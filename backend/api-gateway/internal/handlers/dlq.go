@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/LunarTechAI/octavia/api-gateway/config"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/billing"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/dispatch"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/dlq"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/events"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/fanout"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+const defaultDLQPageSize = 50
+
+type DLQHandler struct {
+	db         *gorm.DB
+	manager    *dlq.Manager
+	dispatcher *dispatch.Dispatcher
+	events     *events.Bus
+	cfg        *config.Config
+}
+
+func NewDLQHandler(db *gorm.DB, manager *dlq.Manager, dispatcher *dispatch.Dispatcher, bus *events.Bus, cfg *config.Config) *DLQHandler {
+	return &DLQHandler{db: db, manager: manager, dispatcher: dispatcher, events: bus, cfg: cfg}
+}
+
+type DLQBulkRequest struct {
+	MessageIDs []string `json:"message_ids"`
+	All        bool     `json:"all"`
+}
+
+type dlqSkipped struct {
+	MessageID string `json:"message_id"`
+	Reason    string `json:"reason"`
+}
+
+type dlqResult struct {
+	Processed []string     `json:"processed"`
+	Skipped   []dlqSkipped `json:"skipped"`
+}
+
+// errSkipMessage leaves a message in the queue without aborting the walk.
+type errSkipMessage struct{ reason string }
+
+func (e *errSkipMessage) Error() string { return e.reason }
+
+// List shows dead-lettered messages without removing them.
+func (h *DLQHandler) List(c *fiber.Ctx) error {
+	limit := defaultDLQPageSize
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid limit")
+		}
+		limit = n
+	}
+
+	messages := []*dlq.Message{}
+	depth, err := h.manager.Walk(c.Context(), limit, func(msg *dlq.Message) (bool, error) {
+		messages = append(messages, msg)
+		return false, nil
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusServiceUnavailable, "Dead-letter queue unavailable")
+	}
+
+	return c.JSON(fiber.Map{
+		"depth":    depth,
+		"messages": messages,
+	})
+}
+
+func (h *DLQHandler) Requeue(c *fiber.Ctx) error {
+	return h.bulk(c, h.requeue)
+}
+
+func (h *DLQHandler) Discard(c *fiber.Ctx) error {
+	return h.bulk(c, h.discard)
+}
+
+func (h *DLQHandler) RequeueOne(c *fiber.Ctx) error {
+	return h.one(c, h.requeue)
+}
+
+func (h *DLQHandler) DiscardOne(c *fiber.Ctx) error {
+	return h.one(c, h.discard)
+}
+
+func (h *DLQHandler) bulk(c *fiber.Ctx, action func(*dlq.Message) error) error {
+	var req DLQBulkRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if !req.All && len(req.MessageIDs) == 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "message_ids or all is required")
+	}
+
+	ids := make(map[string]bool, len(req.MessageIDs))
+	for _, id := range req.MessageIDs {
+		ids[id] = true
+	}
+
+	result, err := h.apply(c, func(msg *dlq.Message) bool { return req.All || ids[msg.MessageID] }, action)
+	if err != nil {
+		return err
+	}
+	return c.JSON(result)
+}
+
+func (h *DLQHandler) one(c *fiber.Ctx, action func(*dlq.Message) error) error {
+	id := c.Params("id")
+	result, err := h.apply(c, func(msg *dlq.Message) bool { return msg.MessageID == id }, action)
+	if err != nil {
+		return err
+	}
+	if len(result.Processed) == 0 && len(result.Skipped) == 0 {
+		return fiber.NewError(fiber.StatusNotFound, "Message not found in dead-letter queue")
+	}
+	return c.JSON(result)
+}
+
+func (h *DLQHandler) apply(c *fiber.Ctx, match func(*dlq.Message) bool, action func(*dlq.Message) error) (*dlqResult, error) {
+	result := &dlqResult{Processed: []string{}, Skipped: []dlqSkipped{}}
+	_, err := h.manager.Walk(c.Context(), 0, func(msg *dlq.Message) (bool, error) {
+		if !match(msg) {
+			return false, nil
+		}
+		if err := action(msg); err != nil {
+			var skip *errSkipMessage
+			if errors.As(err, &skip) {
+				result.Skipped = append(result.Skipped, dlqSkipped{MessageID: msg.MessageID, Reason: skip.reason})
+				return false, nil
+			}
+			return false, err
+		}
+		result.Processed = append(result.Processed, msg.MessageID)
+		return true, nil
+	})
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusServiceUnavailable, "Dead-letter queue unavailable")
+	}
+	return result, nil
+}
+
+// requeue puts the job back to pending and queues a fresh message for it
+// through the outbox, rebuilt from the job row so its signed URLs have not
+// expired and its retry count starts over. A failed job's credits were
+// released when it failed, so the replay holds them again and is skipped if
+// the user can no longer afford it.
+func (h *DLQHandler) requeue(msg *dlq.Message) error {
+	jobID, err := uuid.Parse(msg.JobID)
+	if err != nil {
+		return &errSkipMessage{reason: "not a job message"}
+	}
+
+	var job models.Job
+	var parent *models.Job
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&job, "id = ?", jobID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &errSkipMessage{reason: "job not found"}
+			}
+			return err
+		}
+		if !models.CanRequeue(job.Status) {
+			return &errSkipMessage{reason: "job is " + job.Status}
+		}
+		if err := billing.Rehold(tx, &job); err != nil {
+			if errors.Is(err, billing.ErrInsufficientCredits) {
+				return &errSkipMessage{reason: "insufficient credits"}
+			}
+			return err
+		}
+
+		err := tx.Model(&job).Updates(map[string]interface{}{
+			"status":     models.JobStatusPending,
+			"error":      "",
+			"updated_at": time.Now(),
+			"stage":      "",
+			"progress":   0,
+			"eta":        nil,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if parent, err = fanout.Refresh(tx, &job); err != nil {
+			return err
+		}
+		return h.dispatcher.Enqueue(context.Background(), tx, &job)
+	})
+	if err != nil {
+		return err
+	}
+	h.publishEvent(&job, parent)
+	return nil
+}
+
+// discard drops the message and fails its job if nothing else has settled
+// it yet, refunding the user as for any other failure.
+func (h *DLQHandler) discard(msg *dlq.Message) error {
+	jobID, err := uuid.Parse(msg.JobID)
+	if err != nil {
+		return nil
+	}
+
+	refundPolicy := billing.RefundPolicy{ProcessingRatio: h.cfg.RefundProcessingRatio}
+	var job models.Job
+	var parent *models.Job
+	settled := false
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&job, "id = ?", jobID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+		if models.IsTerminal(job.Status) {
+			return nil
+		}
+
+		from := job.Status
+		reason := msg.FailureReason
+		if reason == "" {
+			reason = "discarded from dead-letter queue"
+		}
+		err := tx.Model(&job).Updates(map[string]interface{}{
+			"status":     models.JobStatusFailed,
+			"error":      reason,
+			"updated_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		if _, err := billing.Release(tx, &job, refundPolicy.Amount(&job, from), "job_failed"); err != nil {
+			return err
+		}
+		settled = true
+		parent, err = fanout.Refresh(tx, &job)
+		return err
+	})
+	if err != nil || !settled {
+		return err
+	}
+	h.publishEvent(&job, parent)
+	return nil
+}
+
+// publishEvent tells streaming clients that an operator moved job, and its
+// parent if it has one.
+func (h *DLQHandler) publishEvent(job, parent *models.Job) {
+	for _, j := range []*models.Job{job, parent} {
+		if j == nil {
+			continue
+		}
+		if err := h.events.PublishJob(context.Background(), j); err != nil {
+			log.Printf("dlq: failed to publish event for %s: %v", j.ID, err)
+		}
+	}
+}
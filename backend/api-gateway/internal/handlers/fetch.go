@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/billing"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/fanout"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/jobkind"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/storage"
+)
+
+var errFetchAbandoned = errors.New("job left the fetching state while downloading")
+
+// RunFetches downloads queued remote sources on cfg.FetchWorkers goroutines
+// and returns once they have all stopped. Cancelling ctx cancels the
+// downloads in flight; jobs still queued stay fetching until the reaper
+// fails them.
+func (h *JobsHandler) RunFetches(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < h.cfg.FetchWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-h.fetches:
+					h.fetchSource(ctx, job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// fetchSource downloads a job's remote source, probes it, charges for the
+// real duration and queues the job. Any failure marks the job failed with a
+// reason the client can show.
+func (h *JobsHandler) fetchSource(workerCtx context.Context, job models.Job) {
+	// This runs on a fetch worker, outside Fiber's recover middleware.
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("fetch: panic while fetching job %s: %v", job.ID, r)
+			h.failFetch(job, "fetcher: internal error")
+		}
+	}()
+
+	// A cancel or the reaper may have settled the job while it was queued.
+	var status string
+	if err := h.db.Model(&models.Job{}).Select("status").Where("id = ?", job.ID).Scan(&status).Error; err != nil {
+		log.Printf("fetch: failed to load job %s: %v", job.ID, err)
+		h.failFetch(job, "fetcher: internal error")
+		return
+	}
+	if status != models.JobStatusFetching {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(workerCtx, time.Duration(h.cfg.FetchTimeout)*time.Second+time.Minute)
+	defer cancel()
+
+	key := storage.UploadKey(job.UserID.String(), job.ID.String(), remoteFileName(job.SourceRemoteURL))
+	res, err := h.fetcher.Fetch(ctx, job.SourceRemoteURL, key)
+	if err != nil {
+		if workerCtx.Err() != nil {
+			h.failFetch(job, "fetcher: download interrupted by a gateway shutdown")
+			return
+		}
+		h.failFetch(job, err.Error())
+		return
+	}
+
+	spec, err := jobkind.Lookup(job.Kind)
+	if err != nil {
+		h.storage.Delete(ctx, res.Key)
+		h.failFetch(job, err.Error())
+		return
+	}
+	var opts jobkind.Options
+	json.Unmarshal(job.Options, &opts)
+
+	info, err := h.probeUpload(ctx, res.Key, spec)
+	if err != nil {
+		h.storage.Delete(ctx, res.Key)
+		if errors.Is(err, errWrongSourceKind) {
+			h.failFetch(job, fmt.Sprintf("fetcher: source file is not suitable for %s", spec.Kind))
+		} else {
+			h.failFetch(job, "fetcher: unsupported or unreadable media file")
+		}
+		return
+	}
+
+	duration := int64(math.Ceil(info.Duration.Seconds()))
+	flagged := false
+	if durationMismatch(job.ClaimedDuration, duration) {
+		if h.cfg.DurationMismatchPolicy != "flag" {
+			h.storage.Delete(ctx, res.Key)
+			h.failFetch(job, fmt.Sprintf("fetcher: claimed duration %ds does not match media duration %ds", job.ClaimedDuration, duration))
+			return
+		}
+		flagged = true
+	}
+	cost := spec.Cost(&opts, duration, h.cfg.CostPerMinute)
+
+	var children []models.Job
+	var parent *models.Job
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		var current models.Job
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, "id = ?", job.ID).Error; err != nil {
+			return err
+		}
+		if current.Status != models.JobStatusFetching {
+			return errFetchAbandoned
+		}
+
+		// A multi-language parent downloads once for all its children; the
+		// ones cancelled meanwhile are left alone.
+		work := []*models.Job{&job}
+		if job.ChildCount > 0 {
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("parent_id = ? AND status = ?", job.ID, models.JobStatusFetching).
+				Find(&children).Error
+			if err != nil {
+				return err
+			}
+			work = work[:0]
+			for i := range children {
+				work = append(work, &children[i])
+			}
+		}
+
+		for _, j := range append([]*models.Job{&job}, work...) {
+			j.SourceFileURL = res.Key
+			j.SourceFileName = path.Base(res.Key)
+			j.SourceFileSize = res.Size
+			j.SourceChecksum = res.Checksum
+			j.SourceMimeType = res.MimeType
+			j.Duration = duration
+			j.DurationFlagged = flagged
+			j.Cost = cost
+			j.Status = models.JobStatusPending
+			j.UpdatedAt = time.Now()
+		}
+		// The parent carries what its children are held, and cancelled
+		// children are neither held nor charged.
+		if job.ChildCount > 0 {
+			job.Cost = cost * float64(len(children))
+		}
+		if err := tx.Save(&job).Error; err != nil {
+			return err
+		}
+
+		for _, j := range work {
+			if j != &job {
+				if err := tx.Save(j).Error; err != nil {
+					return err
+				}
+			}
+			if err := billing.Hold(tx, j); err != nil {
+				return err
+			}
+			if err := h.dispatcher.Enqueue(ctx, tx, j); err != nil {
+				return err
+			}
+		}
+		if len(children) > 0 {
+			var err error
+			parent, err = fanout.Refresh(tx, &children[0])
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		h.storage.Delete(ctx, res.Key)
+		if errors.Is(err, errFetchAbandoned) {
+			return
+		}
+		if errors.Is(err, billing.ErrInsufficientCredits) {
+			h.failFetch(job, "Insufficient credits")
+		} else {
+			log.Printf("fetch: failed to update job %s: %v", job.ID, err)
+			h.failFetch(job, "fetcher: internal error")
+		}
+		return
+	}
+
+	if parent == nil {
+		parent = &job
+	}
+	h.publishEvent(ctx, parent)
+	for i := range children {
+		h.publishEvent(ctx, &children[i])
+	}
+}
+
+// failFetch fails job and, for a multi-language job, its children still
+// waiting on the download.
+func (h *JobsHandler) failFetch(job models.Job, reason string) {
+	var failed []models.Job
+	err := h.db.Model(&failed).Clauses(clause.Returning{}).Where("(id = ? OR parent_id = ?) AND status = ?", job.ID, job.ID, models.JobStatusFetching).Updates(map[string]interface{}{
+		"status":     models.JobStatusFailed,
+		"error":      reason,
+		"updated_at": time.Now(),
+	}).Error
+	if err != nil {
+		log.Printf("fetch: failed to mark job %s failed: %v", job.ID, err)
+		return
+	}
+	for i := range failed {
+		h.publishEvent(context.Background(), &failed[i])
+	}
+}
+
+func remoteFileName(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "source"
+	}
+	return sanitizeFileName(path.Base(u.Path))
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/storage"
+)
+
+// FilesHandler serves signed URLs for drivers that keep objects on the
+// gateway's own disk. S3-compatible drivers hand out bucket URLs instead.
+type FilesHandler struct {
+	storage storage.Storage
+}
+
+func NewFilesHandler(store storage.Storage) *FilesHandler {
+	return &FilesHandler{storage: store}
+}
+
+func (h *FilesHandler) Download(c *fiber.Ctx) error {
+	verifier, ok := h.storage.(storage.URLVerifier)
+	if !ok {
+		return fiber.NewError(fiber.StatusNotFound, "Not found")
+	}
+
+	key, err := url.PathUnescape(c.Params("*"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid key")
+	}
+
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	if err := verifier.VerifySignedURL(fiber.MethodGet, key, query); err != nil {
+		return fiber.NewError(fiber.StatusForbidden, "Invalid or expired signature")
+	}
+
+	obj, err := h.storage.Stat(c.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fiber.NewError(fiber.StatusNotFound, "File not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to read file")
+	}
+
+	r, err := h.storage.Get(c.Context(), key)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to read file")
+	}
+
+	c.Set(fiber.HeaderContentType, obj.ContentType)
+	return c.SendStream(r, int(obj.Size))
+}
+
+// Upload receives objects PUT to a URL from SignedURL, which is how workers
+// store their results when storage is local disk. Unlike presigned client
+// uploads the URL binds only the key, so it is only handed to workers.
+func (h *FilesHandler) Upload(c *fiber.Ctx) error {
+	verifier, ok := h.storage.(storage.URLVerifier)
+	if !ok {
+		return fiber.NewError(fiber.StatusNotFound, "Not found")
+	}
+
+	key, err := url.PathUnescape(c.Params("*"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid key")
+	}
+
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	if err := verifier.VerifySignedURL(fiber.MethodPut, key, query); err != nil {
+		return fiber.NewError(fiber.StatusForbidden, "Invalid or expired signature")
+	}
+
+	contentType := c.Get(fiber.HeaderContentType)
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(key))
+	}
+
+	var body io.Reader = c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+	obj, err := h.storage.Put(c.Context(), key, body, int64(c.Request().Header.ContentLength()), contentType)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to store file")
+	}
+
+	return c.JSON(fiber.Map{
+		"key":  obj.Key,
+		"size": obj.Size,
+	})
+}
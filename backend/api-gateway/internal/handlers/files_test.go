@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/storage"
+)
+
+func TestFilesUploadAndDownload(t *testing.T) {
+	store, err := storage.NewLocal(t.TempDir(), "http://gateway.test/api/v1/files", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewFilesHandler(store)
+	app := fiber.New()
+	app.Get("/api/v1/files/*", h.Download)
+	app.Put("/api/v1/files/*", h.Upload)
+
+	ctx := context.Background()
+	key := storage.ResultKey("user", "job", "wav")
+	sign := func(method string) string {
+		t.Helper()
+		signed, err := store.SignedURL(ctx, method, key, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, _ := url.Parse(signed)
+		return u.RequestURI()
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+	}{
+		{"get signature cannot upload", http.MethodPut, sign(http.MethodGet), http.StatusForbidden},
+		{"unsigned", http.MethodPut, "/api/v1/files/" + key, http.StatusForbidden},
+		{"other key", http.MethodPut, strings.Replace(sign(http.MethodPut), "job.wav", "other.wav", 1), http.StatusForbidden},
+		{"signed", http.MethodPut, sign(http.MethodPut), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader("RIFF result"))
+			req.Header.Set("Content-Type", "audio/wav")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, sign(http.MethodGet), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "RIFF result" {
+		t.Errorf("download = %d %q", resp.StatusCode, body)
+	}
+}
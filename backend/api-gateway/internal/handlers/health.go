@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/db"
+)
+
+type HealthHandler struct {
+	db          *gorm.DB
+	redisClient *redis.Client
+	broker      *db.Broker
+}
+
+func NewHealthHandler(database *gorm.DB, redisClient *redis.Client, broker *db.Broker) *HealthHandler {
+	return &HealthHandler{db: database, redisClient: redisClient, broker: broker}
+}
+
+// Live only says the process is serving requests.
+func (h *HealthHandler) Live(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "healthy"})
+}
+
+// Ready checks every dependency and answers 503 if any of them is down, so
+// the load balancer stops routing here while e.g. the broker reconnects.
+func (h *HealthHandler) Ready(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(c.Context(), 2*time.Second)
+	defer cancel()
+
+	checks := fiber.Map{}
+	ready := true
+	report := func(name string, err error) {
+		if err != nil {
+			checks[name] = err.Error()
+			ready = false
+			return
+		}
+		checks[name] = "ok"
+	}
+
+	sqlDB, err := h.db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	report("database", err)
+	report("redis", h.redisClient.Ping(ctx).Err())
+	report("rabbitmq", h.broker.Healthy())
+
+	status := "ready"
+	code := fiber.StatusOK
+	if !ready {
+		status = "unavailable"
+		code = fiber.StatusServiceUnavailable
+	}
+	return c.Status(code).JSON(fiber.Map{
+		"status": status,
+		"checks": checks,
+	})
+}
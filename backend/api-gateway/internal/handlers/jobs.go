@@ -1,37 +1,78 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"math"
 	"mime/multipart"
+	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
-	"github.com/rabbitmq/amqp091-go"
+	"github.com/valyala/fasthttp"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 
 	"github.com/LunarTechAI/octavia/api-gateway/config"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/billing"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/dispatch"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/events"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/fanout"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/fetcher"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/jobkind"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/media"
 	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/outbox"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/storage"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/tus"
 )
 
 type JobsHandler struct {
-	db            *gorm.DB
-	rabbitChannel *amqp091.Channel
-	cfg           *config.Config
+	db         *gorm.DB
+	storage    storage.Storage
+	uploads    *tus.Store
+	fetcher    *fetcher.Fetcher
+	dispatcher *dispatch.Dispatcher
+	events     *events.Bus
+	cfg        *config.Config
+
+	// fetches feeds remote-source jobs to the RunFetches workers.
+	fetches chan models.Job
 }
 
-func NewJobsHandler(db *gorm.DB, rabbitChannel *amqp091.Channel, cfg *config.Config) *JobsHandler {
-	return &JobsHandler{db: db, rabbitChannel: rabbitChannel, cfg: cfg}
+func NewJobsHandler(db *gorm.DB, store storage.Storage, uploads *tus.Store, fetch *fetcher.Fetcher, dispatcher *dispatch.Dispatcher, bus *events.Bus, cfg *config.Config) *JobsHandler {
+	return &JobsHandler{
+		db:         db,
+		storage:    store,
+		uploads:    uploads,
+		fetcher:    fetch,
+		dispatcher: dispatcher,
+		events:     bus,
+		cfg:        cfg,
+		fetches:    make(chan models.Job, cfg.FetchQueueSize),
+	}
 }
 
+// resultURLExpiry is the lifetime of result download links handed to clients.
+const resultURLExpiry = time.Hour
+
 type JobRequest struct {
+	Kind          string                `form:"kind"`
+	Options       string                `form:"options"`
 	SourceFileURL string                `form:"source_file_url"`
+	UploadKey     string                `form:"upload_key"`
+	UploadID      string                `form:"upload_id"`
 	File          *multipart.FileHeader `form:"file"`
 	SourceLang    string                `form:"source_lang"`
 	TargetLang    string                `form:"target_lang"`
+	TargetLangs   string                `form:"target_langs"`
 	Duration      int64                 `form:"duration"`
 }
 
@@ -39,101 +80,259 @@ type UpdateJobRequest struct {
 	Status    string `json:"status"`
 	ResultURL string `json:"result_url"`
 	Error     string `json:"error"`
+
+	Stage      string `json:"stage"`
+	Progress   *int   `json:"progress"`
+	ETASeconds *int64 `json:"eta_seconds"`
 }
 
 func (h *JobsHandler) CreateJob(c *fiber.Ctx) error {
-	var req JobRequest
-	if c.FormValue("source_lang") == "" || c.FormValue("target_lang") == "" {
-		return fiber.NewError(fiber.StatusBadRequest, "source_lang and target_lang are required")
+	req := JobRequest{
+		Kind:          c.FormValue("kind"),
+		Options:       c.FormValue("options"),
+		SourceFileURL: c.FormValue("source_file_url"),
+		UploadKey:     c.FormValue("upload_key"),
+		UploadID:      c.FormValue("upload_id"),
+		SourceLang:    c.FormValue("source_lang"),
+		TargetLang:    c.FormValue("target_lang"),
+		TargetLangs:   c.FormValue("target_langs"),
 	}
-
-	req.SourceLang = c.FormValue("source_lang")
-	req.TargetLang = c.FormValue("target_lang")
-	req.SourceFileURL = c.FormValue("source_file_url")
-	durationStr := c.FormValue("duration")
-	duration, err := strconv.ParseInt(durationStr, 10, 64)
-	if err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, "Invalid duration")
+	if durationStr := c.FormValue("duration"); durationStr != "" {
+		duration, err := strconv.ParseInt(durationStr, 10, 64)
+		if err != nil || duration < 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid duration")
+		}
+		req.Duration = duration
 	}
-	req.Duration = duration
-	req.Duration = duration
 
 	file, err := c.FormFile("file")
-	if err != nil && err != fiber.ErrUnprocessableEntity {
+	if err != nil && !errors.Is(err, fasthttp.ErrMissingFile) && !errors.Is(err, fasthttp.ErrNoMultipartForm) {
 		return fiber.NewError(fiber.StatusBadRequest, "Error parsing file")
 	}
+	req.File = file
 
-	if file == nil && req.SourceFileURL == "" {
-		return fiber.NewError(fiber.StatusBadRequest, "Either file or source_file_url is required")
+	prepared, err := h.prepareJob(c.Context(), GetUserID(c), &req)
+	if err != nil {
+		return err
 	}
 
-	sourceFileURL := req.SourceFileURL
-	if file != nil {
-		sourceFileURL = file.Filename
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		return h.insertJob(c.Context(), tx, prepared)
+	})
+	if err != nil {
+		h.discardUpload(c.Context(), prepared.upload)
+		if errors.Is(err, billing.ErrInsufficientCredits) {
+			return fiber.NewError(fiber.StatusBadRequest, "Insufficient credits")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Job creation failed")
 	}
+	h.startJob(prepared)
 
-	userID := GetUserID(c)
+	return c.Status(fiber.StatusCreated).JSON(prepared.summary())
+}
+
+// preparedJob is a validated and priced job whose rows are not written yet.
+// work holds the units a worker runs: the job itself, or its children.
+type preparedJob struct {
+	job    models.Job
+	work   []models.Job
+	upload *storedUpload
+}
 
-	cost := float64(req.Duration) * h.cfg.CostPerMinute / 60.0
+// prepareJob resolves and probes req's source, validates it against the
+// kind and prices it. Errors are *fiber.Error ready for the client; a fresh
+// upload is removed again on failure.
+func (h *JobsHandler) prepareJob(ctx context.Context, userID uuid.UUID, req *JobRequest) (*preparedJob, error) {
+	if req.SourceLang == "" || (req.TargetLang == "" && req.TargetLangs == "") {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "source_lang and target_lang or target_langs are required")
+	}
+	targets, err := h.parseTargetLangs(req.TargetLang, req.TargetLangs)
+	if err != nil {
+		return nil, err
+	}
 
-	var user models.User
-	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "User not found")
+	if req.File == nil && req.UploadKey == "" && req.UploadID == "" && req.SourceFileURL == "" {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "One of file, upload_id, upload_key or source_file_url is required")
 	}
 
-	if user.Credits < cost {
-		return fiber.NewError(fiber.StatusBadRequest, "Insufficient credits")
+	spec, err := jobkind.Lookup(req.Kind)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "kind must be one of video_translation, audio_translation, subtitles, subtitle_to_audio")
+	}
+	if !slices.Contains(h.cfg.EnabledJobKinds, spec.Kind) {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("kind %s is not available yet", spec.Kind))
+	}
+	opts, err := spec.ParseOptions(req.Options)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	if spec.Source == jobkind.SourceSubtitle && req.File == nil && req.UploadKey == "" && req.UploadID == "" {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "source_file_url is not supported for subtitle_to_audio; upload the subtitle file instead")
 	}
+	optionsJSON, _ := json.Marshal(opts)
 
 	jobID := uuid.New()
+
+	sourceFileURL := req.SourceFileURL
+	var upload *storedUpload
+	var info *media.Info
+	if req.File != nil {
+		upload, err = h.storeUpload(ctx, req.File, userID, jobID)
+		if err != nil {
+			return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to store upload")
+		}
+		sourceFileURL = upload.Key
+	} else if req.UploadKey != "" || req.UploadID != "" {
+		key := req.UploadKey
+		if req.UploadID != "" {
+			key, err = h.uploads.Claim(ctx, req.UploadID, userID.String())
+			if err != nil {
+				return nil, fiber.NewError(fiber.StatusBadRequest, "Unknown or incomplete upload_id")
+			}
+		}
+		upload, info, err = h.adoptUpload(ctx, key, req.UploadID, userID, spec)
+		if err != nil {
+			if req.UploadID != "" {
+				h.discardUpload(ctx, &storedUpload{tusID: req.UploadID})
+			}
+			switch {
+			case errors.Is(err, errUploadNotFound):
+				return nil, fiber.NewError(fiber.StatusBadRequest, "Unknown upload_key")
+			case errors.Is(err, errWrongSourceKind), errors.Is(err, errUnreadableSource):
+				return nil, sourceRejected(err, spec)
+			}
+			return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to read upload")
+		}
+		sourceFileURL = upload.Key
+	} else if err := h.fetcher.Validate(req.SourceFileURL); err != nil {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "source_file_url must be a public http(s) URL")
+	}
+
+	// The client's duration is only trusted for remote sources the gateway
+	// has not seen; uploads are billed on what the container says.
+	duration := req.Duration
+	claimedDuration := req.Duration
+	durationFlagged := false
+	if upload != nil {
+		// Adopted uploads were probed while they were read.
+		if info == nil {
+			info, err = h.probeUpload(ctx, upload.Key, spec)
+			if err != nil {
+				h.discardUpload(ctx, upload)
+				return nil, sourceRejected(err, spec)
+			}
+		}
+		duration = int64(math.Ceil(info.Duration.Seconds()))
+		if claimedDuration > 0 && durationMismatch(claimedDuration, duration) {
+			if h.cfg.DurationMismatchPolicy != "flag" {
+				h.discardUpload(ctx, upload)
+				return nil, fiber.NewError(fiber.StatusUnprocessableEntity,
+					fmt.Sprintf("Claimed duration %ds does not match media duration %ds", claimedDuration, duration))
+			}
+			durationFlagged = true
+		}
+	} else if duration <= 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "duration is required for source_file_url")
+	}
+
 	job := models.Job{
-		ID:            jobID,
-		UserID:        userID,
-		SourceFileURL: sourceFileURL,
-		SourceLang:    req.SourceLang,
-		TargetLang:    req.TargetLang,
-		Duration:      req.Duration,
-		Status:        "pending",
-		Cost:          cost,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
-	}
-
-	if err := h.db.Create(&job).Error; err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Job creation failed")
+		ID:              jobID,
+		UserID:          userID,
+		Kind:            spec.Kind,
+		Options:         optionsJSON,
+		SourceFileURL:   sourceFileURL,
+		SourceLang:      req.SourceLang,
+		TargetLang:      strings.Join(targets, ","),
+		Duration:        duration,
+		ClaimedDuration: claimedDuration,
+		DurationFlagged: durationFlagged,
+		Status:          models.JobStatusPending,
+		Cost:            spec.Cost(opts, duration, h.cfg.CostPerMinute),
+		CreatedAt:       time.Now(),
+		UpdatedAt:       time.Now(),
 	}
+	if upload != nil {
+		job.SourceFileName = upload.Name
+		job.SourceFileSize = upload.Size
+		job.SourceChecksum = upload.Checksum
+		job.SourceMimeType = upload.MimeType
+	} else {
+		// Remote sources are downloaded in the background and only charged
+		// once their real duration is known.
+		job.SourceRemoteURL = req.SourceFileURL
+		job.Status = models.JobStatusFetching
+	}
+
+	// With several target languages job becomes the parent and children do
+	// the work; otherwise job is its own only unit of work.
+	prepared := &preparedJob{job: job, upload: upload}
+	prepared.work = []models.Job{job}
+	if len(targets) > 1 {
+		prepared.work = fanOut(&prepared.job, targets)
+	}
+	return prepared, nil
+}
 
-	h.db.Transaction(func(tx *gorm.DB) error {
-		tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, "id = ?", userID)
-		if user.Credits < cost {
-			return errors.New("insufficient credits")
+// insertJob writes the job rows, the credit holds, their ledger entries and
+// the queue messages in tx, so a user who cannot afford every language gets
+// none of them.
+func (h *JobsHandler) insertJob(ctx context.Context, tx *gorm.DB, p *preparedJob) error {
+	if err := tx.Create(&p.job).Error; err != nil {
+		return err
+	}
+	if p.job.ChildCount > 0 {
+		if err := tx.Create(&p.work).Error; err != nil {
+			return err
 		}
-		user.Credits -= cost
-		tx.Save(&user)
+	}
+	if p.upload == nil {
 		return nil
-	})
+	}
+	for i := range p.work {
+		if err := billing.Hold(tx, &p.work[i]); err != nil {
+			return err
+		}
+		if err := h.dispatcher.Enqueue(ctx, tx, &p.work[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	jobMsg := map[string]interface{}{
-		"job_id":      jobID.String(),
-		"source_file": sourceFileURL,
-		"source_lang": req.SourceLang,
-		"target_lang": req.TargetLang,
-		"duration":    req.Duration,
-		"user_id":     userID.String(),
-	}
-
-	msgBody, _ := json.Marshal(jobMsg)
-	h.rabbitChannel.Publish("", h.cfg.RabbitMQQueue, false, false, amqp091.Publishing{
-		ContentType: "application/json",
-		Body:        msgBody,
-		MessageId:   jobID.String(),
-		Timestamp:   time.Now(),
-	})
+// startJob queues the download of a remote source once its rows have
+// committed. A job that finds the queue full is failed rather than left to
+// wait out its fetch lease.
+func (h *JobsHandler) startJob(p *preparedJob) {
+	if p.upload == nil {
+		select {
+		case h.fetches <- p.job:
+		default:
+			h.failFetch(p.job, "fetcher: too many downloads in progress, try again later")
+		}
+		return
+	}
+	if p.upload.tusID != "" {
+		if err := h.uploads.Consume(context.Background(), p.upload.tusID); err != nil {
+			log.Printf("jobs: failed to consume upload %s for job %s: %v", p.upload.tusID, p.job.ID, err)
+		}
+	}
+}
 
-	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"id":     jobID.String(),
-		"status": "pending",
-	})
+func (p *preparedJob) summary() fiber.Map {
+	resp := fiber.Map{
+		"id":     p.job.ID.String(),
+		"kind":   p.job.Kind,
+		"status": p.job.Status,
+		"cost":   p.job.Cost,
+	}
+	if p.job.ChildCount > 0 {
+		children := make([]fiber.Map, len(p.work))
+		for i, child := range p.work {
+			children[i] = fiber.Map{"id": child.ID.String(), "target_lang": child.TargetLang, "status": child.Status, "cost": child.Cost}
+		}
+		resp["children"] = children
+	}
+	return resp
 }
 
 func (h *JobsHandler) UpdateJob(c *fiber.Ctx) error {
@@ -147,27 +346,118 @@ func (h *JobsHandler) UpdateJob(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
+	if req.Status != "" && !models.ValidJobStatus(req.Status) {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Unknown status %q", req.Status))
+	}
+
+	if req.Stage != "" && !models.ValidJobStage(req.Stage) {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Unknown stage %q", req.Stage))
+	}
+	if req.Progress != nil && (*req.Progress < 0 || *req.Progress > 100) {
+		return fiber.NewError(fiber.StatusBadRequest, "progress must be between 0 and 100")
+	}
+	if req.ETASeconds != nil && *req.ETASeconds < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "eta_seconds must not be negative")
+	}
+
 	var job models.Job
 	if err := h.db.First(&job, "id = ?", jobID).Error; err != nil {
 		return fiber.NewError(fiber.StatusNotFound, "Job not found")
 	}
 
-	// Update job fields
+	if job.ChildCount > 0 {
+		return fiber.NewError(fiber.StatusConflict, "Multi-language jobs are updated through their children")
+	}
+	if req.Status != "" && !models.CanTransition(job.Status, req.Status) {
+		return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("Cannot move job from %s to %s", job.Status, req.Status))
+	}
+	if req.Status == "" && models.IsTerminal(job.Status) {
+		return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("Job is %s and can no longer be updated", job.Status))
+	}
+
+	// Every callback counts as a heartbeat, including an empty PATCH.
+	now := time.Now()
+	updates := map[string]interface{}{
+		"updated_at":   now,
+		"heartbeat_at": now,
+	}
 	if req.Status != "" {
-		job.Status = req.Status
+		updates["status"] = req.Status
+	}
+	if req.Stage != "" {
+		updates["stage"] = req.Stage
+	}
+	if req.Progress != nil {
+		updates["progress"] = *req.Progress
+	}
+	if req.ETASeconds != nil {
+		updates["eta"] = now.Add(time.Duration(*req.ETASeconds) * time.Second)
+	}
+	switch req.Status {
+	case models.JobStatusCompleted:
+		updates["progress"] = 100
+		updates["eta"] = nil
+	case models.JobStatusFailed, models.JobStatusRetrying, models.JobStatusCancelled:
+		updates["eta"] = nil
 	}
 	if req.ResultURL != "" {
-		job.ResultURL = req.ResultURL
+		updates["result_url"] = req.ResultURL
 	}
 	if req.Error != "" {
-		job.Error = req.Error
+		updates["error"] = req.Error
 	}
-	job.UpdatedAt = time.Now()
 
-	if err := h.db.Save(&job).Error; err != nil {
+	refundPolicy := billing.RefundPolicy{ProcessingRatio: h.cfg.RefundProcessingRatio}
+	var parent *models.Job
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		// The status read above is the precondition: if another callback
+		// moved the job in the meantime nothing is written and the caller
+		// gets a 409.
+		result := tx.Model(&models.Job{}).Where("id = ? AND status = ?", job.ID, job.Status).Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fiber.NewError(fiber.StatusConflict, "Job was updated concurrently")
+		}
+
+		// Every terminal status settles the hold.
+		switch req.Status {
+		case models.JobStatusCompleted:
+			if _, err := billing.Capture(tx, job.ID); err != nil {
+				return err
+			}
+		case models.JobStatusFailed:
+			if _, err := billing.Release(tx, &job, refundPolicy.Amount(&job, job.Status), "job_failed"); err != nil {
+				return err
+			}
+		case models.JobStatusCancelled:
+			// As in cancelOne, a cancelled job is refunded in full.
+			if _, err := billing.Release(tx, &job, job.Cost, "job_cancel"); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		parent, err = fanout.Refresh(tx, &job)
+		return err
+	})
+	if err != nil {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return fiberErr
+		}
 		return fiber.NewError(fiber.StatusInternalServerError, "Failed to update job")
 	}
 
+	if err := h.db.First(&job, "id = ?", jobID).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to reload job")
+	}
+	h.publishEvent(c.Context(), &job)
+	if parent != nil {
+		h.publishEvent(c.Context(), parent)
+	}
+
 	return c.JSON(job)
 }
 
@@ -187,5 +477,125 @@ func (h *JobsHandler) GetJob(c *fiber.Ctx) error {
 		return fiber.NewError(fiber.StatusInternalServerError, "Query failed")
 	}
 
+	h.signResultURL(c.Context(), &job)
+	if err := h.loadChildren(c.Context(), &job); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Query failed")
+	}
 	return c.JSON(job)
 }
+
+// CancelJob stops a job that has not finished yet, or every unfinished
+// child of a multi-language job. The status changes, the refunds and the
+// cancellation messages for workers commit together.
+func (h *JobsHandler) CancelJob(c *fiber.Ctx) error {
+	jobID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid job ID")
+	}
+
+	userID := GetUserID(c)
+
+	var job models.Job
+	var parent *models.Job
+	var refund float64
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ? AND user_id = ?", jobID, userID).First(&job).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(fiber.StatusNotFound, "Job not found")
+			}
+			return err
+		}
+
+		if job.ChildCount == 0 {
+			if err := h.cancelOne(tx, &job); err != nil {
+				return err
+			}
+			refund = job.Refunded
+			parent, err = fanout.Refresh(tx, &job)
+			return err
+		}
+
+		var children []models.Job
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("parent_id = ?", job.ID).Find(&children).Error; err != nil {
+			return err
+		}
+		cancelled := 0
+		for i := range children {
+			if models.IsTerminal(children[i].Status) {
+				continue
+			}
+			if err := h.cancelOne(tx, &children[i]); err != nil {
+				return err
+			}
+			refund += children[i].Refunded
+			cancelled++
+		}
+		if cancelled == 0 {
+			return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("Job is %s and can no longer be cancelled", job.Status))
+		}
+		refreshed, err := fanout.Refresh(tx, &children[0])
+		if err == nil {
+			job = *refreshed
+		}
+		return err
+	})
+	if err != nil {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return fiberErr
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to cancel job")
+	}
+	h.publishEvent(c.Context(), &job)
+	if parent != nil {
+		h.publishEvent(c.Context(), parent)
+	}
+
+	return c.JSON(fiber.Map{
+		"id":       job.ID.String(),
+		"status":   job.Status,
+		"refunded": refund,
+	})
+}
+
+// cancelOne cancels a single unit of work in tx: it refunds whatever was
+// held and tells workers to stop.
+func (h *JobsHandler) cancelOne(tx *gorm.DB, job *models.Job) error {
+	var refund float64
+	switch job.Status {
+	case models.JobStatusPending, models.JobStatusProcessing, models.JobStatusRetrying:
+		refund = job.Cost
+	case models.JobStatusFetching:
+		// Fetching jobs have not been charged yet.
+	default:
+		return fiber.NewError(fiber.StatusConflict, fmt.Sprintf("Job is %s and can no longer be cancelled", job.Status))
+	}
+
+	job.Status = models.JobStatusCancelled
+	job.UpdatedAt = time.Now()
+	if err := tx.Save(job).Error; err != nil {
+		return err
+	}
+
+	if _, err := billing.Release(tx, job, refund, "job_cancel"); err != nil {
+		return err
+	}
+
+	// The job ID is also the routing key so consumers can filter
+	// without decoding.
+	return outbox.Enqueue(tx, h.cfg.RabbitMQCancelExchange, job.ID.String(), "cancel:"+job.ID.String(), map[string]interface{}{
+		"job_id": job.ID.String(),
+		"reason": "cancelled",
+	})
+}
+
+// signResultURL exposes a short-lived download link when the worker reported
+// a storage key rather than an absolute URL.
+func (h *JobsHandler) signResultURL(ctx context.Context, job *models.Job) {
+	if job.ResultURL == "" || strings.Contains(job.ResultURL, "://") {
+		return
+	}
+	if signed, err := h.storage.SignedURL(ctx, http.MethodGet, job.ResultURL, resultURLExpiry); err == nil {
+		job.ResultDownloadURL = signed
+	}
+}
@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/events"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+// streamKeepAlive keeps idle streams from being cut by proxies.
+const streamKeepAlive = 15 * time.Second
+
+// publishEvent tells streaming clients on every replica about job's new
+// state. A lost event only delays the client until the next one.
+func (h *JobsHandler) publishEvent(ctx context.Context, job *models.Job) {
+	if err := h.events.PublishJob(ctx, job); err != nil {
+		log.Printf("jobs: failed to publish event for %s: %v", job.ID, err)
+	}
+}
+
+// subscribeOwnJob subscribes before reading the job, so no update can slip
+// in between the snapshot and the first pushed event.
+func (h *JobsHandler) subscribeOwnJob(jobID, userID uuid.UUID) (*events.Subscription, *events.JobEvent, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sub, err := h.events.SubscribeJob(ctx, jobID)
+	if err != nil {
+		return nil, nil, fiber.NewError(fiber.StatusServiceUnavailable, "Event stream unavailable")
+	}
+
+	var job models.Job
+	if err := h.db.WithContext(ctx).Where("id = ? AND user_id = ?", jobID, userID).First(&job).Error; err != nil {
+		sub.Close()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, fiber.NewError(fiber.StatusNotFound, "Job not found")
+		}
+		return nil, nil, fiber.NewError(fiber.StatusInternalServerError, "Query failed")
+	}
+	return sub, events.NewJobEvent(&job), nil
+}
+
+// pumpJobEvents sends the snapshot and then every event until the job
+// reaches a final status, the subscription ends or a write fails.
+func pumpJobEvents(sub *events.Subscription, snapshot *events.JobEvent, send func(*events.JobEvent) error, ping func() error) {
+	if err := send(snapshot); err != nil || snapshot.Final() {
+		return
+	}
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case event, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if err := send(event); err != nil || event.Final() {
+				return
+			}
+		case <-ticker.C:
+			if err := ping(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// StreamEvents pushes job updates as Server-Sent Events.
+func (h *JobsHandler) StreamEvents(c *fiber.Ctx) error {
+	jobID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid job ID")
+	}
+
+	sub, snapshot, err := h.subscribeOwnJob(jobID, GetUserID(c))
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		send := func(event *events.JobEvent) error {
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "event: job\ndata: %s\n\n", data)
+			return w.Flush()
+		}
+		ping := func() error {
+			fmt.Fprint(w, ": keep-alive\n\n")
+			return w.Flush()
+		}
+		pumpJobEvents(sub, snapshot, send, ping)
+	})
+	return nil
+}
+
+// UpgradeJobSocket authorises a WebSocket stream before the upgrade, while
+// errors can still be returned as plain HTTP responses.
+func (h *JobsHandler) UpgradeJobSocket(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+	jobID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid job ID")
+	}
+
+	sub, snapshot, err := h.subscribeOwnJob(jobID, GetUserID(c))
+	if err != nil {
+		return err
+	}
+	c.Locals("jobSubscription", sub)
+	c.Locals("jobSnapshot", snapshot)
+	// A failed handshake never reaches StreamJobSocket, which would
+	// otherwise own and close the subscription.
+	if err := c.Next(); err != nil {
+		sub.Close()
+		return err
+	}
+	return nil
+}
+
+// StreamJobSocket pushes job updates as JSON text frames.
+func (h *JobsHandler) StreamJobSocket(conn *websocket.Conn) {
+	sub := conn.Locals("jobSubscription").(*events.Subscription)
+	snapshot := conn.Locals("jobSnapshot").(*events.JobEvent)
+	defer sub.Close()
+
+	// Clients only send control frames; reading is how a close is noticed.
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				sub.Close()
+				return
+			}
+		}
+	}()
+
+	send := func(event *events.JobEvent) error {
+		return conn.WriteJSON(event)
+	}
+	ping := func() error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second))
+	}
+	pumpJobEvents(sub, snapshot, send, ping)
+
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+}
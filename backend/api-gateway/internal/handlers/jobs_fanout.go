@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+// parseTargetLangs accepts either a single target_lang or a comma-separated
+// target_langs, dropping blanks and duplicates.
+func (h *JobsHandler) parseTargetLangs(single, list string) ([]string, error) {
+	if single != "" && list != "" {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Send either target_lang or target_langs, not both")
+	}
+	if single != "" {
+		return []string{single}, nil
+	}
+
+	var targets []string
+	for _, lang := range strings.Split(list, ",") {
+		lang = strings.TrimSpace(lang)
+		if lang != "" && !slices.Contains(targets, lang) {
+			targets = append(targets, lang)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fiber.NewError(fiber.StatusBadRequest, "target_langs must list at least one language")
+	}
+	if len(targets) > h.cfg.MaxTargetLangs {
+		return nil, fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("target_langs may list at most %d languages", h.cfg.MaxTargetLangs))
+	}
+	return targets, nil
+}
+
+// fanOut turns parent into the aggregate of one child per target language.
+// Children share the parent's stored source; each is charged the parent's
+// per-language cost, and the parent carries the total.
+func fanOut(parent *models.Job, targets []string) []models.Job {
+	children := make([]models.Job, len(targets))
+	for i, lang := range targets {
+		child := *parent
+		child.ID = uuid.New()
+		child.ParentID = &parent.ID
+		child.TargetLang = lang
+		children[i] = child
+	}
+	parent.ChildCount = len(children)
+	parent.Cost *= float64(len(children))
+	return children
+}
+
+// loadChildren fills in a parent's children for responses.
+func (h *JobsHandler) loadChildren(ctx context.Context, parent *models.Job) error {
+	if parent.ChildCount == 0 {
+		return nil
+	}
+	if err := h.db.Where("parent_id = ?", parent.ID).Order("target_lang").Find(&parent.Children).Error; err != nil {
+		return err
+	}
+	for i := range parent.Children {
+		h.signResultURL(ctx, &parent.Children[i])
+	}
+	return nil
+}
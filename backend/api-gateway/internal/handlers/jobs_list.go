@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+const (
+	defaultJobsPageSize = 20
+	maxJobsPageSize     = 100
+)
+
+// jobSortColumns maps the public sort names to columns. Every sort is
+// tie-broken on id so cursors stay stable when values collide.
+var jobSortColumns = map[string]string{
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"cost":       "cost",
+}
+
+type jobsCursor struct {
+	Sort  string    `json:"s"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"id"`
+}
+
+// ListJobs returns the session user's jobs, newest first by default.
+//
+// Children of multi-language jobs are listed only when parent_id is given.
+//
+// Query: parent_id, batch_id, status, kind (comma separated), source_lang, target_lang, created_after,
+// created_before (RFC 3339), sort (created_at|updated_at|cost),
+// order (asc|desc), limit, cursor.
+func (h *JobsHandler) ListJobs(c *fiber.Ctx) error {
+	userID := GetUserID(c)
+
+	sort := c.Query("sort", "created_at")
+	column, ok := jobSortColumns[sort]
+	if !ok {
+		return fiber.NewError(fiber.StatusBadRequest, "sort must be one of created_at, updated_at, cost")
+	}
+	order := strings.ToLower(c.Query("order", "desc"))
+	if order != "asc" && order != "desc" {
+		return fiber.NewError(fiber.StatusBadRequest, "order must be asc or desc")
+	}
+
+	limit := defaultJobsPageSize
+	if raw := c.Query("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid limit")
+		}
+		if n > maxJobsPageSize {
+			n = maxJobsPageSize
+		}
+		limit = n
+	}
+
+	q := h.db.Model(&models.Job{}).Where("user_id = ?", userID)
+	if raw := c.Query("parent_id"); raw != "" {
+		parentID, err := uuid.Parse(raw)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid parent_id")
+		}
+		q = q.Where("parent_id = ?", parentID)
+	} else {
+		q = q.Where("parent_id IS NULL")
+	}
+
+	if raw := c.Query("status"); raw != "" {
+		q = q.Where("status IN ?", strings.Split(raw, ","))
+	}
+	if raw := c.Query("batch_id"); raw != "" {
+		batchID, err := uuid.Parse(raw)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid batch_id")
+		}
+		q = q.Where("batch_id = ?", batchID)
+	}
+	if raw := c.Query("kind"); raw != "" {
+		q = q.Where("kind IN ?", strings.Split(raw, ","))
+	}
+	if lang := c.Query("source_lang"); lang != "" {
+		q = q.Where("source_lang = ?", lang)
+	}
+	if lang := c.Query("target_lang"); lang != "" {
+		// A parent matches any one of its languages.
+		q = q.Where("? = ANY(string_to_array(target_lang, ','))", lang)
+	}
+	if raw := c.Query("created_after"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "created_after must be RFC 3339")
+		}
+		q = q.Where("created_at >= ?", t)
+	}
+	if raw := c.Query("created_before"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "created_before must be RFC 3339")
+		}
+		q = q.Where("created_at < ?", t)
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		cur, value, err := decodeJobsCursor(raw, sort)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid cursor")
+		}
+		cmp := "<"
+		if order == "asc" {
+			cmp = ">"
+		}
+		q = q.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, cmp), value, cur.ID)
+	}
+
+	var jobs []models.Job
+	if err := q.Order(column + " " + order).Order("id " + order).Limit(limit + 1).Find(&jobs).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Query failed")
+	}
+
+	var nextCursor string
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+		nextCursor = encodeJobsCursor(sort, &jobs[limit-1])
+	}
+	for i := range jobs {
+		h.signResultURL(c.Context(), &jobs[i])
+	}
+
+	return c.JSON(fiber.Map{
+		"jobs":        jobs,
+		"next_cursor": nextCursor,
+	})
+}
+
+func encodeJobsCursor(sort string, job *models.Job) string {
+	cur := jobsCursor{Sort: sort, ID: job.ID}
+	switch sort {
+	case "updated_at":
+		cur.Value = job.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case "cost":
+		cur.Value = strconv.FormatFloat(job.Cost, 'f', -1, 64)
+	default:
+		cur.Value = job.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	data, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeJobsCursor also checks the cursor was issued for the same sort, since
+// a created_at cursor is meaningless when paging by cost.
+func decodeJobsCursor(raw, sort string) (*jobsCursor, interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cur jobsCursor
+	if err := json.Unmarshal(data, &cur); err != nil {
+		return nil, nil, err
+	}
+	if cur.Sort != sort {
+		return nil, nil, fmt.Errorf("cursor was issued for sort %q", cur.Sort)
+	}
+
+	if sort == "cost" {
+		v, err := strconv.ParseFloat(cur.Value, 64)
+		return &cur, v, err
+	}
+	v, err := time.Parse(time.RFC3339Nano, cur.Value)
+	return &cur, v, err
+}
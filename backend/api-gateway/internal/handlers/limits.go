@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyLimitMiddleware restores the request size limit that StreamRequestBody
+// turns off. Bodies up to limit are buffered as usual; larger ones get a 413.
+// Requests for which streamed returns true keep their body stream and must
+// enforce their own limit.
+func BodyLimitMiddleware(limit int64, streamed func(c *fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if streamed(c) {
+			return c.Next()
+		}
+		if int64(c.Request().Header.ContentLength()) > limit {
+			return tooLarge(c)
+		}
+		stream := c.Request().BodyStream()
+		if stream == nil {
+			return c.Next()
+		}
+
+		// Chunked bodies carry no Content-Length, so count while reading.
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, io.LimitReader(stream, limit+1)); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "Failed to read request body")
+		}
+		if int64(buf.Len()) > limit {
+			return tooLarge(c)
+		}
+		c.Request().SetBody(buf.Bytes())
+		return c.Next()
+	}
+}
+
+// tooLarge closes the connection, since the rest of the body is still
+// unread on it.
+func tooLarge(c *fiber.Ctx) error {
+	c.Context().SetConnectionClose()
+	return fiber.ErrRequestEntityTooLarge
+}
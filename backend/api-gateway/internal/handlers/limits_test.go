@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	app := fiber.New(fiber.Config{BodyLimit: 16, StreamRequestBody: true})
+	app.Use(BodyLimitMiddleware(16, func(c *fiber.Ctx) bool { return c.Method() == fiber.MethodPut }))
+	app.Post("/json", func(c *fiber.Ctx) error { return c.Send(c.Body()) })
+	app.Put("/upload", func(c *fiber.Ctx) error {
+		n, err := io.Copy(io.Discard, c.Context().RequestBodyStream())
+		if err != nil {
+			return err
+		}
+		return c.SendString(strings.Repeat("x", int(n)))
+	})
+
+	tests := []struct {
+		name    string
+		method  string
+		body    string
+		chunked bool
+		status  int
+	}{
+		{"small", http.MethodPost, `{"a":1}`, false, http.StatusOK},
+		{"at limit", http.MethodPost, strings.Repeat("a", 16), false, http.StatusOK},
+		{"over limit", http.MethodPost, strings.Repeat("a", 4096), false, http.StatusRequestEntityTooLarge},
+		{"chunked over limit", http.MethodPost, strings.Repeat("a", 4096), true, http.StatusRequestEntityTooLarge},
+		{"chunked small", http.MethodPost, "hello", true, http.StatusOK},
+		{"streamed route", http.MethodPut, strings.Repeat("a", 4096), false, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := "/json"
+			if tt.method == http.MethodPut {
+				path = "/upload"
+			}
+			req := httptest.NewRequest(tt.method, path, strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == http.StatusOK {
+				got, _ := io.ReadAll(resp.Body)
+				if len(got) != len(tt.body) {
+					t.Errorf("handler saw %d bytes, want %d", len(got), len(tt.body))
+				}
+			}
+		})
+	}
+}
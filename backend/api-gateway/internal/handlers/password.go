@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/mailer"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/tokens"
+)
+
+const (
+	purposeResetPassword = "reset_password"
+
+	passwordResetCooldown = time.Minute
+	passwordResetSendTime = 30 * time.Second
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,min=8"`
+}
+
+// ForgotPassword emails a reset link if the address belongs to an account.
+// The token and email are produced after the response is sent, so neither
+// the body nor the timing reveals whether the account exists.
+func (h *AuthHandler) ForgotPassword(c *fiber.Ctx) error {
+	var req ForgotPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	var user models.User
+	if h.db.Where("email = ?", req.Email).First(&user).Error == nil && user.Status != models.UserStatusSuspended {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), passwordResetSendTime)
+			defer cancel()
+			if err := h.sendPasswordReset(ctx, &user); err != nil {
+				log.Printf("auth: failed to send password reset to user %s: %v", user.ID, err)
+			}
+		}()
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"success": true})
+}
+
+func (h *AuthHandler) sendPasswordReset(ctx context.Context, user *models.User) error {
+	cooldownKey := "password:forgot:" + user.ID.String()
+	if ok, err := h.redis.SetNX(ctx, cooldownKey, 1, passwordResetCooldown).Result(); err != nil || !ok {
+		return err
+	}
+
+	ttl := time.Duration(h.cfg.PasswordResetTokenTTL) * time.Second
+	token, err := h.tokens.Issue(ctx, purposeResetPassword, user.ID.String(), user.ID.String(), ttl)
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(h.cfg.FrontendURL, "/") + "/reset-password?token=" + url.QueryEscape(token)
+	return h.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Octavia password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password for your Octavia account. Choose a new one by opening this link:\n\n%s\n\nThe link expires in %s and works once. If you did not ask for this, ignore this email; your password stays the same.\n",
+			user.Name, link, ttl),
+	})
+}
+
+// ResetPassword sets a new password from a reset token and signs the user
+// out everywhere.
+func (h *AuthHandler) ResetPassword(c *fiber.Ctx) error {
+	var req ResetPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	userID, err := h.tokens.Consume(c.Context(), purposeResetPassword, req.Token)
+	if err != nil {
+		if errors.Is(err, tokens.ErrInvalid) {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired reset token")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Password reset failed")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Password reset failed")
+	}
+
+	var user models.User
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+		if user.Status == models.UserStatusSuspended {
+			return tokens.ErrInvalid
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"password":   string(hashedPassword),
+			"updated_at": now,
+		}
+		// The link reached the inbox, which is what verification proves.
+		if user.Status == models.UserStatusPendingVerification {
+			updates["status"] = models.UserStatusActive
+			updates["email_verified_at"] = now
+		}
+		return tx.Model(&user).Updates(updates).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, tokens.ErrInvalid) {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired reset token")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Password reset failed")
+	}
+
+	if err := revokeUserSessions(c.Context(), h.redis, user.ID, ""); err != nil {
+		log.Printf("auth: failed to revoke sessions for user %s: %v", user.ID, err)
+		return fiber.NewError(fiber.StatusInternalServerError, "Password changed but sessions could not be revoked")
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
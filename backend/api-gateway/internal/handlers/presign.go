@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/LunarTechAI/octavia/api-gateway/config"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/storage"
+)
+
+type UploadHandler struct {
+	storage   storage.Storage
+	cfg       *config.Config
+	validator *validator.Validate
+}
+
+func NewUploadHandler(store storage.Storage, cfg *config.Config) *UploadHandler {
+	return &UploadHandler{storage: store, cfg: cfg, validator: validator.New()}
+}
+
+type PresignRequest struct {
+	FileName    string `json:"file_name" validate:"required"`
+	FileSize    int64  `json:"file_size" validate:"required,min=1"`
+	ContentType string `json:"content_type"`
+	Language    string `json:"language"`
+}
+
+// Presign issues a short-lived URL the client can PUT the file to directly.
+// The returned upload_key is then passed to POST /jobs instead of a file.
+func (h *UploadHandler) Presign(c *fiber.Ctx) error {
+	var req PresignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+	if req.FileSize > h.cfg.MaxUploadSize {
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, "File exceeds maximum upload size")
+	}
+
+	contentType := req.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(req.FileName))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	userID := GetUserID(c)
+	key := storage.UploadKey(userID.String(), uuid.New().String(), sanitizeFileName(req.FileName))
+	ttl := time.Duration(h.cfg.PresignTTL) * time.Second
+	expiresAt := time.Now().Add(ttl)
+
+	resp := fiber.Map{
+		"upload_key":   key,
+		"file_path":    key,
+		"content_type": contentType,
+		"max_size":     req.FileSize,
+		"expires_at":   expiresAt.UTC().Format(time.RFC3339),
+	}
+
+	// A presigned PUT to an object store binds neither the content type nor
+	// the size, so such stores get a POST policy that binds both.
+	switch store := h.storage.(type) {
+	case storage.URLVerifier:
+		resp["upload_url"] = h.localUploadURL(key, contentType, req.FileSize, expiresAt)
+		resp["method"] = fiber.MethodPut
+	case storage.PostSigner:
+		uploadURL, fields, err := store.SignedPost(c.Context(), key, contentType, req.FileSize, ttl)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "Failed to sign upload URL")
+		}
+		resp["upload_url"] = uploadURL
+		resp["method"] = fiber.MethodPost
+		resp["fields"] = fields
+	default:
+		return fiber.NewError(fiber.StatusInternalServerError, "Storage cannot sign uploads")
+	}
+
+	return c.JSON(resp)
+}
+
+// LocalPut receives uploads for presigned URLs when storage is local disk.
+// The signature binds the key (and so the user), content type and max size.
+func (h *UploadHandler) LocalPut(c *fiber.Ctx) error {
+	if _, ok := h.storage.(storage.URLVerifier); !ok {
+		return fiber.NewError(fiber.StatusNotFound, "Not found")
+	}
+
+	key, err := url.PathUnescape(c.Params("*"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid key")
+	}
+	key, err = storage.CleanKey(key)
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid key")
+	}
+
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	contentType := query.Get("content_type")
+	maxSize, err := strconv.ParseInt(query.Get("max_size"), 10, 64)
+	if err != nil {
+		return fiber.NewError(fiber.StatusForbidden, "Invalid or expired signature")
+	}
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return fiber.NewError(fiber.StatusForbidden, "Invalid or expired signature")
+	}
+	expected := h.signUpload(key, contentType, maxSize, expires)
+	if !hmac.Equal([]byte(query.Get("signature")), []byte(expected)) {
+		return fiber.NewError(fiber.StatusForbidden, "Invalid or expired signature")
+	}
+
+	if got, _, _ := mime.ParseMediaType(c.Get(fiber.HeaderContentType)); got != contentType {
+		if base, _, _ := mime.ParseMediaType(contentType); got != base {
+			return fiber.NewError(fiber.StatusBadRequest, "Content-Type does not match presigned upload")
+		}
+	}
+
+	size := int64(c.Request().Header.ContentLength())
+	if size > maxSize {
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, "File exceeds presigned size")
+	}
+
+	// Presigned URLs are single-use: never overwrite an existing object.
+	if _, err := h.storage.Stat(c.Context(), key); err == nil {
+		return fiber.NewError(fiber.StatusConflict, "Upload already completed")
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to check upload")
+	}
+
+	// c.Body() would drain the stream into memory, so only fall back to it
+	// when fasthttp already buffered a small body.
+	var body io.Reader = c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+	if size < 0 {
+		body = &maxBytesReader{r: body, n: maxSize}
+	}
+
+	obj, err := h.storage.Put(c.Context(), key, body, size, contentType)
+	if err != nil {
+		if errors.Is(err, errBodyTooLarge) {
+			return fiber.NewError(fiber.StatusRequestEntityTooLarge, "File exceeds presigned size")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to store upload")
+	}
+
+	return c.JSON(fiber.Map{
+		"upload_key": obj.Key,
+		"size":       obj.Size,
+	})
+}
+
+func (h *UploadHandler) localUploadURL(key, contentType string, maxSize int64, expiresAt time.Time) string {
+	q := url.Values{}
+	q.Set("content_type", contentType)
+	q.Set("max_size", strconv.FormatInt(maxSize, 10))
+	q.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	q.Set("signature", h.signUpload(key, contentType, maxSize, expiresAt.Unix()))
+	return h.cfg.PublicURL + "/api/v1/upload/" + key + "?" + q.Encode()
+}
+
+func (h *UploadHandler) signUpload(key, contentType string, maxSize, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(h.cfg.StorageSigningSecret))
+	fmt.Fprintf(mac, "PUT\n%s\n%s\n%d\n%d", key, contentType, maxSize, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// maxBytesReader fails once more than n bytes have been read, for chunked
+// bodies that arrive without a Content-Length.
+type maxBytesReader struct {
+	r io.Reader
+	n int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if m.n < 0 {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > m.n+1 {
+		p = p[:m.n+1]
+	}
+	n, err := m.r.Read(p)
+	m.n -= int64(n)
+	if m.n < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+var errEmailTaken = errors.New("email address is already in use")
+
+// UpdateProfileRequest changes only the fields that are present. An empty
+// avatar_url removes the avatar.
+type UpdateProfileRequest struct {
+	Name      *string `json:"name" validate:"omitempty,min=1,max=255"`
+	Email     *string `json:"email" validate:"omitempty,email,max=255"`
+	AvatarURL *string `json:"avatar_url"`
+	Locale    *string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,min=8"`
+	// SignOutOthers revokes every session except the one making the request.
+	SignOutOthers bool `json:"sign_out_others"`
+}
+
+func profileResponse(user *models.User) fiber.Map {
+	return fiber.Map{
+		"id":             user.ID.String(),
+		"email":          user.Email,
+		"pending_email":  user.PendingEmail,
+		"name":           user.Name,
+		"avatar_url":     user.AvatarURL,
+		"locale":         user.Locale,
+		"credits":        user.Credits,
+		"status":         user.Status,
+		"email_verified": user.EmailVerifiedAt != nil,
+		"two_factor":     user.TOTPEnabledAt != nil,
+	}
+}
+
+func ensureEmailFree(tx *gorm.DB, email string, userID uuid.UUID) error {
+	var count int64
+	if err := tx.Model(&models.User{}).Where("email = ? AND id <> ?", email, userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errEmailTaken
+	}
+	return nil
+}
+
+// UpdateMe edits the caller's profile. A new email address is held as
+// pending and only replaces the current one once it is verified.
+func (h *AuthHandler) UpdateMe(c *fiber.Ctx) error {
+	var req UpdateProfileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+	if req.AvatarURL != nil && *req.AvatarURL != "" {
+		if err := h.validator.Var(*req.AvatarURL, "http_url,max=2048"); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "avatar_url must be an http(s) URL")
+		}
+	}
+
+	var user models.User
+	var verifyAddress string
+	dropped := false
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", GetUserID(c)).First(&user).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{}
+		if req.Name != nil {
+			user.Name = *req.Name
+			updates["name"] = user.Name
+		}
+		if req.AvatarURL != nil {
+			user.AvatarURL = *req.AvatarURL
+			updates["avatar_url"] = user.AvatarURL
+		}
+		if req.Locale != nil {
+			user.Locale = *req.Locale
+			updates["locale"] = user.Locale
+		}
+		if req.Email != nil {
+			switch {
+			case strings.EqualFold(*req.Email, user.Email):
+				// Asking for the current address drops a pending change.
+				dropped = user.PendingEmail != nil
+				user.PendingEmail = nil
+				updates["pending_email"] = nil
+			case user.PendingEmail != nil && strings.EqualFold(*req.Email, *user.PendingEmail):
+				// Already pending; the existing link still works.
+			default:
+				if err := ensureEmailFree(tx, *req.Email, user.ID); err != nil {
+					return err
+				}
+				user.PendingEmail = req.Email
+				updates["pending_email"] = *req.Email
+				verifyAddress = *req.Email
+			}
+		}
+		if len(updates) == 0 {
+			return nil
+		}
+		updates["updated_at"] = time.Now()
+		return tx.Model(&user).Updates(updates).Error
+	})
+	if err != nil {
+		if errors.Is(err, errEmailTaken) {
+			return fiber.NewError(fiber.StatusConflict, "Email address is already in use")
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fiber.NewError(fiber.StatusUnauthorized, "User not found")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to update profile")
+	}
+
+	if verifyAddress != "" {
+		if err := h.sendVerification(c.Context(), &user, purposeChangeEmail, verifyAddress); err != nil {
+			log.Printf("auth: failed to send verification to user %s: %v", user.ID, err)
+		}
+	} else if dropped {
+		if err := h.tokens.Revoke(c.Context(), purposeChangeEmail, user.ID.String()); err != nil {
+			log.Printf("auth: failed to revoke email change link for user %s: %v", user.ID, err)
+		}
+	}
+
+	return c.JSON(profileResponse(&user))
+}
+
+// ChangePassword replaces the caller's password after checking the current
+// one. Outstanding reset links stop working.
+func (h *AuthHandler) ChangePassword(c *fiber.Ctx) error {
+	var req ChangePasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ?", GetUserID(c)).First(&user).Error; err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "User not found")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.CurrentPassword)); err != nil {
+		return fiber.NewError(fiber.StatusForbidden, "Current password is incorrect")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to change password")
+	}
+	err = h.db.Model(&user).Updates(map[string]interface{}{
+		"password":   string(hashedPassword),
+		"updated_at": time.Now(),
+	}).Error
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to change password")
+	}
+
+	if err := h.tokens.Revoke(c.Context(), purposeResetPassword, user.ID.String()); err != nil {
+		log.Printf("auth: failed to revoke reset token for user %s: %v", user.ID, err)
+	}
+	if req.SignOutOthers {
+		if err := revokeUserSessions(c.Context(), h.redis, user.ID, c.Cookies(h.cfg.SessionCookieName)); err != nil {
+			log.Printf("auth: failed to revoke sessions for user %s: %v", user.ID, err)
+			return fiber.NewError(fiber.StatusInternalServerError, "Password changed but other sessions could not be signed out")
+		}
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
@@ -1,14 +1,52 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
+// userSessionsKey indexes a user's session IDs so they can all be revoked at
+// once. Members may outlive their sess: key; deleting those is a no-op.
+func userSessionsKey(userID uuid.UUID) string {
+	return "user_sessions:" + userID.String()
+}
+
+// indexSession records sessionID under userID. The index lives as long as
+// the newest session.
+func indexSession(ctx context.Context, redisClient *redis.Client, userID uuid.UUID, sessionID string, ttl time.Duration) error {
+	pipe := redisClient.TxPipeline()
+	pipe.SAdd(ctx, userSessionsKey(userID), sessionID)
+	pipe.Expire(ctx, userSessionsKey(userID), ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// revokeUserSessions deletes every session of userID except keep, which may
+// be empty.
+func revokeUserSessions(ctx context.Context, redisClient *redis.Client, userID uuid.UUID, keep string) error {
+	ids, err := redisClient.SMembers(ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	pipe := redisClient.TxPipeline()
+	for _, id := range ids {
+		if id == keep {
+			continue
+		}
+		pipe.Del(ctx, fmt.Sprintf("sess:%s", id))
+		pipe.SRem(ctx, userSessionsKey(userID), id)
+	}
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
 func SessionAuthMiddleware(redisClient *redis.Client, cookieName string, ttl int) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		sessionID := c.Cookies(cookieName)
@@ -54,3 +92,13 @@ func WorkerAuthMiddleware(internalAPIKey string) fiber.Handler {
 		return c.Next()
 	}
 }
+
+// AdminAuthMiddleware guards operator endpoints. An empty key disables them.
+func AdminAuthMiddleware(adminAPIKey string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if adminAPIKey == "" || c.Get("X-Admin-API-Key") != adminAPIKey {
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid admin API key")
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/sso"
+)
+
+const (
+	// ssoStateTTL bounds how long the user may spend at the provider.
+	ssoStateTTL = 10 * time.Minute
+	ssoReturnTo = "/dashboard"
+)
+
+var (
+	errSSOEmailUnverified = errors.New("provider did not report a verified email")
+	errSSOSuspended       = errors.New("account suspended")
+)
+
+// ssoState is kept in Redis between the redirect to the provider and the
+// callback. The PKCE verifier and nonce never reach the browser.
+type ssoState struct {
+	Provider string `json:"provider"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	ReturnTo string `json:"return_to"`
+}
+
+func ssoStateKey(state string) string {
+	return "oidc:state:" + state
+}
+
+func (h *AuthHandler) ssoStateCookieName() string {
+	return h.cfg.SessionCookieName + "_oidc"
+}
+
+// ListSSOProviders names the configured providers for login buttons.
+func (h *AuthHandler) ListSSOProviders(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"providers": h.sso.Names()})
+}
+
+// StartSSO redirects the browser to the provider. The state is also set in
+// a cookie so a callback only completes in the browser that started it.
+func (h *AuthHandler) StartSSO(c *fiber.Ctx) error {
+	provider, ok := h.sso.Get(c.Params("provider"))
+	if !ok {
+		return fiber.NewError(fiber.StatusNotFound, "Unknown login provider")
+	}
+
+	state := generateSessionID()
+	pending := ssoState{
+		Provider: provider.Name,
+		Nonce:    generateSessionID(),
+		Verifier: oauth2.GenerateVerifier(),
+		ReturnTo: safeReturnTo(c.Query("return_to")),
+	}
+	authURL, err := provider.AuthCodeURL(state, pending.Nonce, pending.Verifier)
+	if err != nil {
+		log.Printf("auth: %v", err)
+		return fiber.NewError(fiber.StatusBadGateway, "Login provider unavailable")
+	}
+
+	data, _ := json.Marshal(pending)
+	if err := h.redis.Set(c.Context(), ssoStateKey(state), data, ssoStateTTL).Err(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to start login")
+	}
+
+	// Apple posts the callback cross-site, which only SameSite=None cookies
+	// survive, and browsers only accept those over HTTPS.
+	secure := strings.HasPrefix(h.cfg.PublicURL, "https://")
+	sameSite := fiber.CookieSameSiteLaxMode
+	if secure {
+		sameSite = fiber.CookieSameSiteNoneMode
+	}
+	c.Cookie(&fiber.Cookie{
+		Name:     h.ssoStateCookieName(),
+		Value:    state,
+		Expires:  time.Now().Add(ssoStateTTL),
+		HTTPOnly: true,
+		Secure:   secure,
+		SameSite: sameSite,
+		Path:     "/api/v1/auth/oidc",
+	})
+
+	return c.Redirect(authURL, fiber.StatusFound)
+}
+
+// SSOCallback finishes the flow and sends the browser back to the frontend,
+// signed in or with ?error=<reason> on the login page.
+func (h *AuthHandler) SSOCallback(c *fiber.Ctx) error {
+	param := c.Query
+	if c.Method() == fiber.MethodPost {
+		param = c.FormValue
+	}
+
+	cookieState := c.Cookies(h.ssoStateCookieName())
+	c.Cookie(&fiber.Cookie{
+		Name:    h.ssoStateCookieName(),
+		Expires: time.Now().Add(-time.Hour),
+		Path:    "/api/v1/auth/oidc",
+	})
+
+	if param("error") != "" {
+		return h.ssoFail(c, "provider_denied")
+	}
+	state := param("state")
+	if state == "" || state != cookieState {
+		return h.ssoFail(c, "invalid_state")
+	}
+	data, err := h.redis.GetDel(c.Context(), ssoStateKey(state)).Result()
+	if err != nil {
+		return h.ssoFail(c, "invalid_state")
+	}
+	var pending ssoState
+	if json.Unmarshal([]byte(data), &pending) != nil || pending.Provider != c.Params("provider") {
+		return h.ssoFail(c, "invalid_state")
+	}
+
+	provider, ok := h.sso.Get(pending.Provider)
+	if !ok {
+		return h.ssoFail(c, "invalid_state")
+	}
+	identity, err := provider.Exchange(c.Context(), param("code"), pending.Verifier, pending.Nonce)
+	if err != nil {
+		log.Printf("auth: %v", err)
+		return h.ssoFail(c, "login_failed")
+	}
+
+	user, err := h.linkIdentity(identity)
+	switch {
+	case errors.Is(err, errSSOEmailUnverified):
+		return h.ssoFail(c, "email_unverified")
+	case errors.Is(err, errSSOSuspended):
+		return h.ssoFail(c, "account_suspended")
+	case err != nil:
+		log.Printf("auth: failed to link %s identity: %v", identity.Provider, err)
+		return h.ssoFail(c, "login_failed")
+	}
+
+	frontend := strings.TrimRight(h.cfg.FrontendURL, "/")
+	if user.TOTPEnabledAt != nil {
+		token, err := h.createMFAPending(c.Context(), user)
+		if err != nil {
+			return h.ssoFail(c, "login_failed")
+		}
+		return c.Redirect(frontend+"/login/2fa?mfa_token="+url.QueryEscape(token)+"&return_to="+url.QueryEscape(pending.ReturnTo), fiber.StatusFound)
+	}
+
+	sessionID := generateSessionID()
+	if err := h.setSession(c, sessionID, user.ID); err != nil {
+		log.Printf("auth: failed to create session for user %s: %v", user.ID, err)
+		return h.ssoFail(c, "login_failed")
+	}
+	h.setSessionCookie(c, sessionID)
+	return c.Redirect(frontend+pending.ReturnTo, fiber.StatusFound)
+}
+
+func (h *AuthHandler) ssoFail(c *fiber.Ctx, reason string) error {
+	return c.Redirect(strings.TrimRight(h.cfg.FrontendURL, "/")+"/login?error="+reason, fiber.StatusFound)
+}
+
+// linkIdentity finds the user behind identity. An identity seen before maps
+// to its user; otherwise it is linked to the account with the same verified
+// email, or a new account is created for it.
+func (h *AuthHandler) linkIdentity(identity *sso.Identity) (*models.User, error) {
+	var user models.User
+	revokeSessions := false
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var link models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", identity.Provider, identity.Subject).First(&link).Error
+		if err == nil {
+			if err := tx.Where("id = ?", link.UserID).First(&user).Error; err != nil {
+				return err
+			}
+			if identity.Email != "" && identity.Email != link.Email {
+				return tx.Model(&link).Updates(map[string]interface{}{"email": identity.Email, "updated_at": time.Now()}).Error
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		// Linking by email is only safe when the provider vouches for it.
+		if identity.Email == "" || !identity.EmailVerified {
+			return errSSOEmailUnverified
+		}
+
+		now := time.Now()
+		err = tx.Where("LOWER(email) = LOWER(?)", identity.Email).First(&user).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			name := identity.Name
+			if name == "" {
+				name, _, _ = strings.Cut(identity.Email, "@")
+			}
+			// No password: the account signs in through the provider until
+			// one is set with the reset flow.
+			user = models.User{
+				ID:              uuid.New(),
+				Email:           identity.Email,
+				Name:            name,
+				Status:          models.UserStatusActive,
+				EmailVerifiedAt: &now,
+				CreatedAt:       now,
+				UpdatedAt:       now,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case user.Status == models.UserStatusPendingVerification:
+			// Whoever signed up never proved the address, so their password
+			// and sessions must not survive the real owner's arrival.
+			user.Status = models.UserStatusActive
+			user.EmailVerifiedAt = &now
+			user.Password = ""
+			err := tx.Model(&user).Updates(map[string]interface{}{
+				"status":            user.Status,
+				"email_verified_at": now,
+				"password":          "",
+				"updated_at":        now,
+			}).Error
+			if err != nil {
+				return err
+			}
+			revokeSessions = true
+		}
+
+		return tx.Create(&models.UserIdentity{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			Provider:  identity.Provider,
+			Subject:   identity.Subject,
+			Email:     identity.Email,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The squatter's sessions go only once the takeover has committed.
+	if revokeSessions {
+		if err := revokeUserSessions(context.Background(), h.redis, user.ID, ""); err != nil {
+			return nil, err
+		}
+	}
+	if user.Status == models.UserStatusSuspended {
+		return nil, errSSOSuspended
+	}
+	return &user, nil
+}
+
+// safeReturnTo keeps the post-login redirect on the frontend.
+func safeReturnTo(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.ContainsAny(path, "\\\r\n") {
+		return ssoReturnTo
+	}
+	return path
+}
@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/LunarTechAI/octavia/api-gateway/config"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/tus"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,expiration,termination"
+)
+
+// TusHandler implements the tus 1.0.0 core protocol plus the creation,
+// expiration and termination extensions under /api/v1/uploads.
+type TusHandler struct {
+	uploads *tus.Store
+	cfg     *config.Config
+}
+
+func NewTusHandler(uploads *tus.Store, cfg *config.Config) *TusHandler {
+	return &TusHandler{uploads: uploads, cfg: cfg}
+}
+
+func (h *TusHandler) Options(c *fiber.Ctx) error {
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set("Tus-Version", tusVersion)
+	c.Set("Tus-Extension", tusExtensions)
+	c.Set("Tus-Max-Size", strconv.FormatInt(h.cfg.MaxUploadSize, 10))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *TusHandler) Create(c *fiber.Ctx) error {
+	if err := h.checkVersion(c); err != nil {
+		return err
+	}
+
+	length, err := strconv.ParseInt(c.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Upload-Length is required")
+	}
+	if length > h.cfg.MaxUploadSize {
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, "Upload-Length exceeds Tus-Max-Size")
+	}
+
+	meta := parseTusMetadata(c.Get("Upload-Metadata"))
+	u, err := h.uploads.Create(c.Context(), GetUserID(c).String(), length, sanitizeFileName(meta["filename"]), meta["filetype"])
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create upload")
+	}
+
+	c.Set(fiber.HeaderLocation, h.cfg.PublicURL+"/api/v1/uploads/"+u.ID)
+	c.Set("Upload-Expires", u.ExpiresAt.UTC().Format(time.RFC1123))
+	c.Set("Upload-Offset", "0")
+	return c.SendStatus(fiber.StatusCreated)
+}
+
+func (h *TusHandler) Head(c *fiber.Ctx) error {
+	c.Set("Tus-Resumable", tusVersion)
+	c.Set(fiber.HeaderCacheControl, "no-store")
+
+	u, err := h.lookup(c)
+	if err != nil {
+		return err
+	}
+
+	c.Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	c.Set("Upload-Length", strconv.FormatInt(u.Length, 10))
+	c.Set("Upload-Expires", u.ExpiresAt.UTC().Format(time.RFC1123))
+	return c.SendStatus(fiber.StatusOK)
+}
+
+func (h *TusHandler) Patch(c *fiber.Ctx) error {
+	if err := h.checkVersion(c); err != nil {
+		return err
+	}
+	if c.Get(fiber.HeaderContentType) != "application/offset+octet-stream" {
+		return fiber.NewError(fiber.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream")
+	}
+	offset, err := strconv.ParseInt(c.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "Upload-Offset is required")
+	}
+
+	if _, err := h.lookup(c); err != nil {
+		return err
+	}
+
+	// As in LocalPut, c.Body() is only safe once there is no stream to drain.
+	var body io.Reader = c.Context().RequestBodyStream()
+	if body == nil {
+		body = bytes.NewReader(c.Body())
+	}
+
+	u, err := h.uploads.Append(c.Context(), c.Params("id"), offset, body, int64(c.Request().Header.ContentLength()))
+	switch {
+	case errors.Is(err, tus.ErrNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "Upload not found")
+	case errors.Is(err, tus.ErrOffsetMismatch), errors.Is(err, tus.ErrCompleted):
+		return fiber.NewError(fiber.StatusConflict, "Upload-Offset does not match")
+	case errors.Is(err, tus.ErrLocked):
+		return fiber.NewError(fiber.StatusLocked, "Upload is in use by another request")
+	case errors.Is(err, tus.ErrTooLarge):
+		return fiber.NewError(fiber.StatusRequestEntityTooLarge, "Chunk exceeds Upload-Length")
+	case err != nil:
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to store chunk")
+	}
+
+	c.Set("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	c.Set("Upload-Expires", u.ExpiresAt.UTC().Format(time.RFC1123))
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *TusHandler) Delete(c *fiber.Ctx) error {
+	if err := h.checkVersion(c); err != nil {
+		return err
+	}
+	if _, err := h.lookup(c); err != nil {
+		return err
+	}
+	err := h.uploads.Terminate(c.Context(), c.Params("id"))
+	switch {
+	case errors.Is(err, tus.ErrLocked):
+		return fiber.NewError(fiber.StatusLocked, "Upload is being attached to a job")
+	case err != nil:
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to terminate upload")
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// lookup loads the upload and hides uploads owned by other users.
+func (h *TusHandler) lookup(c *fiber.Ctx) (*tus.Upload, error) {
+	u, err := h.uploads.Get(c.Context(), c.Params("id"))
+	if errors.Is(err, tus.ErrNotFound) {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Upload not found")
+	}
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to load upload")
+	}
+	if u.UserID != GetUserID(c).String() {
+		return nil, fiber.NewError(fiber.StatusNotFound, "Upload not found")
+	}
+	return u, nil
+}
+
+func (h *TusHandler) checkVersion(c *fiber.Ctx) error {
+	c.Set("Tus-Resumable", tusVersion)
+	if c.Get("Tus-Resumable") != tusVersion {
+		c.Set("Tus-Version", tusVersion)
+		return fiber.NewError(fiber.StatusPreconditionFailed, "Unsupported Tus-Resumable version")
+	}
+	return nil
+}
+
+// parseTusMetadata decodes "key base64value,key2 base64value2".
+func parseTusMetadata(header string) map[string]string {
+	meta := make(map[string]string)
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), " ", 2)
+		if parts[0] == "" {
+			continue
+		}
+		if len(parts) == 1 {
+			meta[parts[0]] = ""
+			continue
+		}
+		if v, err := base64.StdEncoding.DecodeString(parts[1]); err == nil {
+			meta[parts[0]] = string(v)
+		}
+	}
+	return meta
+}
@@ -0,0 +1,414 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+const (
+	// mfaSetupTTL is how long an unconfirmed enrolment secret is kept.
+	mfaSetupTTL = 10 * time.Minute
+	// mfaMaxAttempts bounds code guesses per password login.
+	mfaMaxAttempts = 5
+	// mfaMaxFailures bounds wrong codes per user across logins and every
+	// other endpoint that checks one, within mfaFailureWindow.
+	mfaMaxFailures   = 10
+	mfaFailureWindow = 15 * time.Minute
+	// mfaCodeReuseWindow covers the validation skew so an accepted code
+	// cannot be replayed.
+	mfaCodeReuseWindow = 90 * time.Second
+)
+
+var (
+	errInvalidSecondFactor = errors.New("invalid second factor")
+	errSecondFactorLocked  = errors.New("too many invalid second factors")
+
+	errTooManySecondFactors = fiber.NewError(fiber.StatusTooManyRequests, "Too many invalid codes, try again later")
+)
+
+type TwoFactorLoginRequest struct {
+	MFAToken     string `json:"mfa_token" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type DisableTwoFactorRequest struct {
+	Password     string `json:"password" validate:"required"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code"`
+}
+
+func mfaPendingKey(token string) string {
+	return "mfa_pending:" + token
+}
+
+func mfaSetupKey(userID uuid.UUID) string {
+	return "mfa:setup:" + userID.String()
+}
+
+func mfaFailuresKey(userID uuid.UUID) string {
+	return "mfa:failures:" + userID.String()
+}
+
+// createMFAPending records that user passed the first factor. The token
+// only lets the caller submit a code; it is not a session.
+func (h *AuthHandler) createMFAPending(ctx context.Context, user *models.User) (string, error) {
+	token := generateSessionID()
+	data, _ := json.Marshal(map[string]interface{}{
+		"user_id":    user.ID.String(),
+		"created_at": time.Now().Format(time.RFC3339),
+	})
+	ttl := time.Duration(h.cfg.MFAPendingTTL) * time.Second
+	if err := h.redis.Set(ctx, mfaPendingKey(token), data, ttl).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// beginMFA answers a correct password for an account with 2FA.
+func (h *AuthHandler) beginMFA(c *fiber.Ctx, user *models.User) error {
+	token, err := h.createMFAPending(c.Context(), user)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Login failed")
+	}
+
+	return c.JSON(fiber.Map{
+		"mfa_required": true,
+		"mfa_token":    token,
+		"expires_in":   h.cfg.MFAPendingTTL,
+	})
+}
+
+// LoginTwoFactor finishes a login started with a password by checking a
+// TOTP or recovery code, then creates the real session.
+func (h *AuthHandler) LoginTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorLoginRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	ctx := c.Context()
+	key := mfaPendingKey(req.MFAToken)
+	data, err := h.redis.Get(ctx, key).Result()
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Login attempt expired, sign in again")
+	}
+	var pending map[string]string
+	json.Unmarshal([]byte(data), &pending)
+
+	attempts, err := h.redis.Incr(ctx, key+":attempts").Result()
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Login failed")
+	}
+	h.redis.Expire(ctx, key+":attempts", time.Duration(h.cfg.MFAPendingTTL)*time.Second)
+	if attempts > mfaMaxAttempts {
+		h.redis.Del(ctx, key, key+":attempts")
+		return fiber.NewError(fiber.StatusUnauthorized, "Too many attempts, sign in again")
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ?", pending["user_id"]).First(&user).Error; err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Login attempt expired, sign in again")
+	}
+	if user.Status == models.UserStatusSuspended {
+		return fiber.NewError(fiber.StatusForbidden, "Account suspended")
+	}
+	if err := h.checkSecondFactor(ctx, &user, req.Code, req.RecoveryCode); err != nil {
+		switch {
+		case errors.Is(err, errInvalidSecondFactor):
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid code")
+		case errors.Is(err, errSecondFactorLocked):
+			return errTooManySecondFactors
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Login failed")
+	}
+
+	// Only one request may turn the pending login into a session.
+	if n, err := h.redis.Del(ctx, key, key+":attempts").Result(); err != nil || n == 0 {
+		return fiber.NewError(fiber.StatusUnauthorized, "Login attempt expired, sign in again")
+	}
+	return h.completeLogin(c, &user)
+}
+
+// checkSecondFactor runs verifySecondFactor behind a per-user failure limit,
+// so guesses cannot be spread over fresh logins or other endpoints. The
+// attempt is counted before the check so concurrent guesses cannot slip
+// past the limit, and the count is cleared by a correct code.
+func (h *AuthHandler) checkSecondFactor(ctx context.Context, user *models.User, code, recoveryCode string) error {
+	key := mfaFailuresKey(user.ID)
+	pipe := h.redis.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	// The window runs from the first failure, so repeated guesses cannot
+	// keep the account locked indefinitely.
+	pipe.ExpireNX(ctx, key, mfaFailureWindow)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if incr.Val() > mfaMaxFailures {
+		return errSecondFactorLocked
+	}
+
+	if err := h.verifySecondFactor(ctx, user, code, recoveryCode); err != nil {
+		return err
+	}
+	h.redis.Del(ctx, key)
+	return nil
+}
+
+// verifySecondFactor accepts either a current TOTP code, once, or an unused
+// recovery code, which it spends.
+func (h *AuthHandler) verifySecondFactor(ctx context.Context, user *models.User, code, recoveryCode string) error {
+	if user.TOTPEnabledAt == nil {
+		return errInvalidSecondFactor
+	}
+
+	if code != "" {
+		secret, err := h.mfa.Open(user.TOTPSecret)
+		if err != nil {
+			return err
+		}
+		if !h.mfa.Validate(secret, code) {
+			return errInvalidSecondFactor
+		}
+		return h.markCodeUsed(ctx, user.ID, code)
+	}
+
+	result := h.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, h.mfa.HashRecoveryCode(recoveryCode)).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidSecondFactor
+	}
+	return nil
+}
+
+func (h *AuthHandler) markCodeUsed(ctx context.Context, userID uuid.UUID, code string) error {
+	ok, err := h.redis.SetNX(ctx, "mfa:used:"+userID.String()+":"+code, 1, mfaCodeReuseWindow).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errInvalidSecondFactor
+	}
+	return nil
+}
+
+// replaceRecoveryCodes discards the user's recovery codes and returns a new
+// set; the plain codes are never shown again.
+func (h *AuthHandler) replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	codes, hashes, err := h.mfa.RecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]models.RecoveryCode, len(hashes))
+	for i, hash := range hashes {
+		rows[i] = models.RecoveryCode{ID: uuid.New(), UserID: userID, CodeHash: hash, CreatedAt: time.Now()}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// SetupTwoFactor starts enrolment. The secret is held in Redis until
+// ConfirmTwoFactor proves the authenticator app has it.
+func (h *AuthHandler) SetupTwoFactor(c *fiber.Ctx) error {
+	var user models.User
+	if err := h.db.Where("id = ?", GetUserID(c)).First(&user).Error; err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "User not found")
+	}
+	if user.TOTPEnabledAt != nil {
+		return fiber.NewError(fiber.StatusConflict, "Two-factor authentication is already enabled")
+	}
+
+	secret, uri, err := h.mfa.Generate(user.Email)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to start enrolment")
+	}
+	sealed, err := h.mfa.Seal(secret)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to start enrolment")
+	}
+	if err := h.redis.Set(c.Context(), mfaSetupKey(user.ID), sealed, mfaSetupTTL).Err(); err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to start enrolment")
+	}
+
+	return c.JSON(fiber.Map{
+		"secret":      secret,
+		"otpauth_url": uri,
+		"expires_in":  int(mfaSetupTTL.Seconds()),
+	})
+}
+
+// ConfirmTwoFactor enables 2FA once a code from the new secret checks out
+// and returns the first set of recovery codes.
+func (h *AuthHandler) ConfirmTwoFactor(c *fiber.Ctx) error {
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	userID := GetUserID(c)
+	sealed, err := h.redis.Get(c.Context(), mfaSetupKey(userID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return fiber.NewError(fiber.StatusConflict, "No enrolment in progress, start again")
+	}
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to confirm enrolment")
+	}
+	secret, err := h.mfa.Open(sealed)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to confirm enrolment")
+	}
+	// The caller was just shown this secret, so a wrong code here is a typo
+	// rather than a guess and is not counted by checkSecondFactor.
+	if !h.mfa.Validate(secret, req.Code) {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid code")
+	}
+
+	var codes []string
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND totp_enabled_at IS NULL", userID).
+			Updates(map[string]interface{}{"totp_secret": sealed, "totp_enabled_at": now, "updated_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fiber.NewError(fiber.StatusConflict, "Two-factor authentication is already enabled")
+		}
+		var err error
+		codes, err = h.replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return fiberErr
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to confirm enrolment")
+	}
+
+	h.redis.Del(c.Context(), mfaSetupKey(userID))
+	h.markCodeUsed(c.Context(), userID, req.Code)
+
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
+
+// DisableTwoFactor needs the password and a second factor, so a stolen
+// session alone cannot remove it.
+func (h *AuthHandler) DisableTwoFactor(c *fiber.Ctx) error {
+	var req DisableTwoFactorRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ?", GetUserID(c)).First(&user).Error; err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "User not found")
+	}
+	if user.TOTPEnabledAt == nil {
+		return fiber.NewError(fiber.StatusConflict, "Two-factor authentication is not enabled")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
+		return fiber.NewError(fiber.StatusForbidden, "Password is incorrect")
+	}
+	if err := h.checkSecondFactor(c.Context(), &user, req.Code, req.RecoveryCode); err != nil {
+		switch {
+		case errors.Is(err, errInvalidSecondFactor):
+			return fiber.NewError(fiber.StatusForbidden, "Invalid code")
+		case errors.Is(err, errSecondFactorLocked):
+			return errTooManySecondFactors
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to disable two-factor authentication")
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"updated_at":      time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to disable two-factor authentication")
+	}
+
+	return c.JSON(fiber.Map{"success": true})
+}
+
+// RegenerateRecoveryCodes replaces every recovery code, used or not, after
+// checking a TOTP code.
+func (h *AuthHandler) RegenerateRecoveryCodes(c *fiber.Ctx) error {
+	var req TwoFactorCodeRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ?", GetUserID(c)).First(&user).Error; err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "User not found")
+	}
+	if user.TOTPEnabledAt == nil {
+		return fiber.NewError(fiber.StatusConflict, "Two-factor authentication is not enabled")
+	}
+	if err := h.checkSecondFactor(c.Context(), &user, req.Code, ""); err != nil {
+		switch {
+		case errors.Is(err, errInvalidSecondFactor):
+			return fiber.NewError(fiber.StatusForbidden, "Invalid code")
+		case errors.Is(err, errSecondFactorLocked):
+			return errTooManySecondFactors
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to regenerate recovery codes")
+	}
+
+	var codes []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = h.replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "Failed to regenerate recovery codes")
+	}
+
+	return c.JSON(fiber.Map{"recovery_codes": codes})
+}
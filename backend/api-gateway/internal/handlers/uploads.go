@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/jobkind"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/media"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/storage"
+)
+
+type storedUpload struct {
+	Key      string
+	Name     string
+	Size     int64
+	Checksum string
+	MimeType string
+
+	// fresh uploads were written by this request and are removed if job
+	// creation fails; presigned and tus uploads are left for a retry.
+	fresh bool
+	// tusID is set for a claimed tus upload, which is released if job
+	// creation fails and consumed once it commits.
+	tusID string
+}
+
+// storeUpload streams a multipart file to uploads/<user>/<job>/<name> in the
+// configured storage, hashing it on the way so the file is only read once.
+func (h *JobsHandler) storeUpload(ctx context.Context, file *multipart.FileHeader, userID, jobID uuid.UUID) (*storedUpload, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	// Sniff the first 512 bytes before streaming the rest.
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	mimeType := http.DetectContentType(head)
+	if declared := file.Header.Get("Content-Type"); mimeType == "application/octet-stream" && declared != "" {
+		mimeType = declared
+	}
+
+	name := sanitizeFileName(file.Filename)
+	key := storage.UploadKey(userID.String(), jobID.String(), name)
+
+	hasher := sha256.New()
+	body := io.TeeReader(io.MultiReader(bytes.NewReader(head), src), hasher)
+	obj, err := h.storage.Put(ctx, key, body, file.Size, mimeType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storedUpload{
+		Key:      obj.Key,
+		Name:     name,
+		Size:     obj.Size,
+		Checksum: hex.EncodeToString(hasher.Sum(nil)),
+		MimeType: mimeType,
+		fresh:    true,
+	}, nil
+}
+
+var (
+	errUploadNotFound  = errors.New("upload not found")
+	errWrongSourceKind = errors.New("source does not suit the job kind")
+	// errUnreadableSource wraps probe failures of a source read without error.
+	errUnreadableSource = errors.New("unsupported or unreadable media file")
+)
+
+// adoptUpload turns a presigned upload key, or the key of the tus upload
+// tusID claimed, into a job source and probes it for spec. A presigned key
+// must live under the caller's own upload prefix; tus objects live elsewhere
+// so they are only adopted through their claim. The object is downloaded
+// once: it is hashed as it streams and probed from the same copy, spooled to
+// a temp file if the driver's reader cannot seek.
+func (h *JobsHandler) adoptUpload(ctx context.Context, key, tusID string, userID uuid.UUID, spec *jobkind.Spec) (*storedUpload, *media.Info, error) {
+	key, err := storage.CleanKey(key)
+	if err != nil {
+		return nil, nil, errUploadNotFound
+	}
+	if tusID == "" && !strings.HasPrefix(key, storage.UploadKey(userID.String(), "", "")+"/") {
+		return nil, nil, errUploadNotFound
+	}
+
+	obj, err := h.storage.Stat(ctx, key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil, errUploadNotFound
+		}
+		return nil, nil, err
+	}
+
+	r, err := h.storage.Get(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+
+	var src io.Reader = r
+	ra, ok := r.(io.ReaderAt)
+	if !ok {
+		tmp, err := os.CreateTemp("", "probe-*")
+		if err != nil {
+			return nil, nil, err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		src, ra = io.TeeReader(r, tmp), tmp
+	}
+
+	hasher := sha256.New()
+	body := io.TeeReader(src, hasher)
+	head := make([]byte, 512)
+	n, err := io.ReadFull(body, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, nil, err
+	}
+	head = head[:n]
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return nil, nil, err
+	}
+
+	info, err := probeSource(ra, obj.Size, spec)
+	if err != nil {
+		if !errors.Is(err, errWrongSourceKind) {
+			err = fmt.Errorf("%w: %v", errUnreadableSource, err)
+		}
+		return nil, nil, err
+	}
+
+	mimeType := http.DetectContentType(head)
+	if mimeType == "application/octet-stream" && obj.ContentType != "" {
+		mimeType = obj.ContentType
+	}
+
+	return &storedUpload{
+		Key:      obj.Key,
+		Name:     path.Base(obj.Key),
+		Size:     obj.Size,
+		Checksum: hex.EncodeToString(hasher.Sum(nil)),
+		MimeType: mimeType,
+		tusID:    tusID,
+	}, info, nil
+}
+
+// probeUpload reads container headers of a stored object to find its real
+// duration, and checks it is the kind of source spec works on. Drivers that
+// cannot seek are spooled to a temp file first.
+func (h *JobsHandler) probeUpload(ctx context.Context, key string, spec *jobkind.Spec) (*media.Info, error) {
+	r, err := h.storage.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	obj, err := h.storage.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if ra, ok := r.(io.ReaderAt); ok {
+		return probeSource(ra, obj.Size, spec)
+	}
+
+	tmp, err := os.CreateTemp("", "probe-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, r); err != nil {
+		return nil, err
+	}
+	return probeSource(tmp, obj.Size, spec)
+}
+
+func probeSource(ra io.ReaderAt, size int64, spec *jobkind.Spec) (*media.Info, error) {
+	probe := media.Probe
+	if spec.Source == jobkind.SourceSubtitle {
+		probe = media.ProbeSubtitles
+	}
+	info, err := probe(ra, size)
+	if err != nil {
+		return nil, err
+	}
+	if !spec.Accepts(info.Format) {
+		return nil, errWrongSourceKind
+	}
+	return info, nil
+}
+
+// sourceRejected is the client error for a source that failed probing.
+func sourceRejected(err error, spec *jobkind.Spec) error {
+	if errors.Is(err, errWrongSourceKind) {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, fmt.Sprintf("Source file is not suitable for %s", spec.Kind))
+	}
+	return fiber.NewError(fiber.StatusUnprocessableEntity, "Unsupported or unreadable media file")
+}
+
+// durationMismatch allows a little slack for rounding by clients that read
+// the duration from a <video> element.
+func durationMismatch(claimed, probed int64) bool {
+	diff := claimed - probed
+	if diff < 0 {
+		diff = -diff
+	}
+	tolerance := probed / 20
+	if tolerance < 2 {
+		tolerance = 2
+	}
+	return diff > tolerance
+}
+
+func (h *JobsHandler) discardUpload(ctx context.Context, upload *storedUpload) {
+	switch {
+	case upload == nil:
+	case upload.tusID != "":
+		if err := h.uploads.Release(ctx, upload.tusID); err != nil {
+			log.Printf("jobs: failed to release upload %s: %v", upload.tusID, err)
+		}
+	case upload.fresh:
+		h.storage.Delete(ctx, upload.Key)
+	}
+}
+
+func sanitizeFileName(name string) string {
+	name = name[strings.LastIndexAny(name, "/\\")+1:]
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, name)
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		return "source"
+	}
+	return name
+}
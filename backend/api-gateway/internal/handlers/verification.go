@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/mailer"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/tokens"
+)
+
+const (
+	purposeVerifyEmail = "verify_email"
+	// purposeChangeEmail links confirm a pending address change. They are
+	// kept apart from sign-up links so neither revokes nor stands in for
+	// the other.
+	purposeChangeEmail = "change_email"
+
+	// verificationResendCooldown stops resend from being used to flood an
+	// inbox.
+	verificationResendCooldown = time.Minute
+)
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// verificationClaim is what a verification token resolves to. The address
+// is part of it so a link only verifies the address it was sent to.
+type verificationClaim struct {
+	UserID string `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+// sendVerification emails user a link that proves they own address, for
+// purposeVerifyEmail (their sign-up address) or purposeChangeEmail (the
+// address they are changing to).
+func (h *AuthHandler) sendVerification(ctx context.Context, user *models.User, purpose, address string) error {
+	claim, _ := json.Marshal(verificationClaim{UserID: user.ID.String(), Email: address})
+	token, err := h.tokens.Issue(ctx, purpose, user.ID.String(), string(claim), time.Duration(h.cfg.VerificationTokenTTL)*time.Second)
+	if err != nil {
+		return err
+	}
+
+	ignore := "If you did not sign up for Octavia, ignore this email."
+	if purpose == purposeChangeEmail {
+		ignore = "If you did not ask to change your Octavia email address, ignore this email."
+	}
+	link := strings.TrimRight(h.cfg.FrontendURL, "/") + "/verify-email?token=" + url.QueryEscape(token)
+	return h.mailer.Send(ctx, mailer.Message{
+		To:      address,
+		Subject: "Verify your Octavia email address",
+		Body: fmt.Sprintf("Hi %s,\n\nConfirm your email address by opening this link:\n\n%s\n\nThe link expires in %s. %s\n",
+			user.Name, link, time.Duration(h.cfg.VerificationTokenTTL)*time.Second, ignore),
+	})
+}
+
+// VerifyEmail activates the account a verification token was issued for, or
+// completes the address change a change_email token was issued for.
+func (h *AuthHandler) VerifyEmail(c *fiber.Ctx) error {
+	var req VerifyEmailRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	// Tokens are signed for their purpose, so only the right one resolves.
+	purpose := purposeVerifyEmail
+	payload, err := h.tokens.Consume(c.Context(), purpose, req.Token)
+	if errors.Is(err, tokens.ErrInvalid) {
+		purpose = purposeChangeEmail
+		payload, err = h.tokens.Consume(c.Context(), purpose, req.Token)
+	}
+	if err != nil {
+		if errors.Is(err, tokens.ErrInvalid) {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired verification token")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Verification failed")
+	}
+	var claim verificationClaim
+	if err := json.Unmarshal([]byte(payload), &claim); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired verification token")
+	}
+
+	var user models.User
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", claim.UserID).First(&user).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{
+			"email_verified_at": now,
+			"updated_at":        now,
+		}
+		switch {
+		case purpose == purposeVerifyEmail && strings.EqualFold(user.Email, claim.Email):
+		case purpose == purposeChangeEmail && user.PendingEmail != nil && strings.EqualFold(*user.PendingEmail, claim.Email):
+			if err := ensureEmailFree(tx, *user.PendingEmail, user.ID); err != nil {
+				return err
+			}
+			user.Email, user.PendingEmail = *user.PendingEmail, nil
+			updates["email"] = user.Email
+			updates["pending_email"] = nil
+		default:
+			return tokens.ErrInvalid
+		}
+		if user.Status == models.UserStatusPendingVerification {
+			user.Status = models.UserStatusActive
+			updates["status"] = user.Status
+		}
+		return tx.Model(&user).Updates(updates).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, tokens.ErrInvalid) {
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid or expired verification token")
+		}
+		if errors.Is(err, errEmailTaken) {
+			return fiber.NewError(fiber.StatusConflict, "Email address is already in use")
+		}
+		return fiber.NewError(fiber.StatusInternalServerError, "Verification failed")
+	}
+
+	return c.JSON(fiber.Map{
+		"id":     user.ID.String(),
+		"email":  user.Email,
+		"status": user.Status,
+	})
+}
+
+// ResendVerification sends a fresh link, revoking the previous one. The
+// response is the same whether or not the address belongs to an account
+// awaiting verification.
+func (h *AuthHandler) ResendVerification(c *fiber.Ctx) error {
+	var req ResendVerificationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+	if err := h.validator.Struct(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Validation error: %v", err))
+	}
+
+	var user models.User
+	if h.db.Where("email = ?", req.Email).First(&user).Error == nil && user.Status == models.UserStatusPendingVerification {
+		cooldownKey := "verify:resend:" + user.ID.String()
+		if ok, err := h.redis.SetNX(c.Context(), cooldownKey, 1, verificationResendCooldown).Result(); err == nil && ok {
+			if err := h.sendVerification(c.Context(), &user, purposeVerifyEmail, user.Email); err != nil {
+				log.Printf("auth: failed to send verification to user %s: %v", user.ID, err)
+			}
+		}
+	}
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"success": true})
+}
+
+// RequireActive stops accounts that are unverified or suspended. It runs
+// after SessionAuthMiddleware.
+func (h *AuthHandler) RequireActive(c *fiber.Ctx) error {
+	var user models.User
+	if err := h.db.Select("status").Where("id = ?", GetUserID(c)).First(&user).Error; err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "User not found")
+	}
+	switch user.Status {
+	case models.UserStatusActive:
+		return c.Next()
+	case models.UserStatusPendingVerification:
+		return fiber.NewError(fiber.StatusForbidden, "Verify your email address first")
+	}
+	return fiber.NewError(fiber.StatusForbidden, "Account suspended")
+}
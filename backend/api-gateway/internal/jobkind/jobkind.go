@@ -0,0 +1,199 @@
+// Package jobkind defines the kinds of work a job can request, the options
+// each kind accepts and how each is priced.
+package jobkind
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+)
+
+const (
+	VideoTranslation = "video_translation"
+	AudioTranslation = "audio_translation"
+	Subtitles        = "subtitles"
+	SubtitleToAudio  = "subtitle_to_audio"
+
+	// Default is assumed when a client does not send a kind, which keeps
+	// requests written before kinds existed working.
+	Default = AudioTranslation
+)
+
+// What a kind expects its source file to be.
+const (
+	SourceMedia    = "media"
+	SourceVideo    = "video"
+	SourceSubtitle = "subtitle"
+)
+
+// Video translation modes.
+const (
+	ModeDubbing   = "dubbing"
+	ModeSubtitles = "subtitles"
+)
+
+// How subtitles are delivered with a translated video.
+const (
+	DeliverySidecar = "sidecar"
+	DeliveryBurnIn  = "burn_in"
+)
+
+// Options is the union of every kind's settings. ParseOptions rejects fields
+// that do not apply to the kind and fills in defaults.
+type Options struct {
+	Mode             string `json:"mode,omitempty"`
+	VoiceID          string `json:"voice_id,omitempty"`
+	OutputFormat     string `json:"output_format,omitempty"`
+	SubtitleFormat   string `json:"subtitle_format,omitempty"`
+	SubtitleDelivery string `json:"subtitle_delivery,omitempty"`
+}
+
+type Spec struct {
+	Kind   string
+	Source string
+	// validate checks and defaults opts in place.
+	validate func(opts *Options) error
+	// multiplier scales the per-minute rate.
+	multiplier func(opts *Options) float64
+}
+
+var (
+	audioFormats    = []string{"wav", "mp3", "ogg"}
+	videoFormats    = []string{"mp4", "mkv", "webm"}
+	subtitleFormats = []string{"srt", "vtt"}
+)
+
+var specs = map[string]*Spec{
+	AudioTranslation: {
+		Kind:   AudioTranslation,
+		Source: SourceMedia,
+		validate: func(o *Options) error {
+			if err := unsupported(AudioTranslation, o.Mode, "mode", o.SubtitleFormat, "subtitle_format", o.SubtitleDelivery, "subtitle_delivery"); err != nil {
+				return err
+			}
+			return oneOf("output_format", &o.OutputFormat, audioFormats)
+		},
+		multiplier: func(*Options) float64 { return 1.0 },
+	},
+	VideoTranslation: {
+		Kind:   VideoTranslation,
+		Source: SourceVideo,
+		validate: func(o *Options) error {
+			if err := oneOf("mode", &o.Mode, []string{ModeDubbing, ModeSubtitles}); err != nil {
+				return err
+			}
+			if err := oneOf("output_format", &o.OutputFormat, videoFormats); err != nil {
+				return err
+			}
+			if o.Mode == ModeDubbing {
+				return unsupported("dubbing", o.SubtitleFormat, "subtitle_format", o.SubtitleDelivery, "subtitle_delivery")
+			}
+			if err := unsupported("subtitles mode", o.VoiceID, "voice_id"); err != nil {
+				return err
+			}
+			if err := oneOf("subtitle_delivery", &o.SubtitleDelivery, []string{DeliverySidecar, DeliveryBurnIn}); err != nil {
+				return err
+			}
+			return oneOf("subtitle_format", &o.SubtitleFormat, subtitleFormats)
+		},
+		multiplier: func(o *Options) float64 {
+			if o.Mode == ModeSubtitles {
+				if o.SubtitleDelivery == DeliveryBurnIn {
+					return 0.75
+				}
+				return 0.5
+			}
+			return 1.5
+		},
+	},
+	Subtitles: {
+		Kind:   Subtitles,
+		Source: SourceMedia,
+		validate: func(o *Options) error {
+			if err := unsupported(Subtitles, o.Mode, "mode", o.VoiceID, "voice_id", o.OutputFormat, "output_format", o.SubtitleDelivery, "subtitle_delivery"); err != nil {
+				return err
+			}
+			return oneOf("subtitle_format", &o.SubtitleFormat, subtitleFormats)
+		},
+		multiplier: func(*Options) float64 { return 0.5 },
+	},
+	SubtitleToAudio: {
+		Kind:   SubtitleToAudio,
+		Source: SourceSubtitle,
+		validate: func(o *Options) error {
+			if err := unsupported(SubtitleToAudio, o.Mode, "mode", o.SubtitleFormat, "subtitle_format", o.SubtitleDelivery, "subtitle_delivery"); err != nil {
+				return err
+			}
+			return oneOf("output_format", &o.OutputFormat, audioFormats)
+		},
+		multiplier: func(*Options) float64 { return 0.75 },
+	},
+}
+
+// Lookup returns the spec for kind, treating "" as Default.
+func Lookup(kind string) (*Spec, error) {
+	if kind == "" {
+		kind = Default
+	}
+	spec, ok := specs[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown kind %q", kind)
+	}
+	return spec, nil
+}
+
+// ParseOptions decodes raw (which may be empty) and validates it for the
+// kind. Unknown fields are an error so typos do not silently fall back to
+// defaults.
+func (s *Spec) ParseOptions(raw string) (*Options, error) {
+	opts := &Options{}
+	if raw != "" {
+		dec := json.NewDecoder(bytes.NewReader([]byte(raw)))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(opts); err != nil {
+			return nil, fmt.Errorf("invalid options: %v", err)
+		}
+	}
+	if err := s.validate(opts); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// Accepts reports whether a probed source format suits the kind.
+func (s *Spec) Accepts(format string) bool {
+	switch s.Source {
+	case SourceVideo:
+		return format == "mp4" || format == "matroska"
+	case SourceSubtitle:
+		return format == "srt" || format == "vtt"
+	}
+	return format != "srt" && format != "vtt"
+}
+
+// Cost prices durationSeconds of source at the base per-minute rate.
+func (s *Spec) Cost(opts *Options, durationSeconds int64, costPerMinute float64) float64 {
+	return float64(durationSeconds) * costPerMinute / 60.0 * s.multiplier(opts)
+}
+
+func oneOf(field string, value *string, allowed []string) error {
+	if *value == "" {
+		*value = allowed[0]
+		return nil
+	}
+	if !slices.Contains(allowed, *value) {
+		return fmt.Errorf("%s must be one of %v", field, allowed)
+	}
+	return nil
+}
+
+// unsupported takes (value, name) pairs and rejects the first one set.
+func unsupported(context string, pairs ...string) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i] != "" {
+			return fmt.Errorf("%s is not supported for %s", pairs[i+1], context)
+		}
+	}
+	return nil
+}
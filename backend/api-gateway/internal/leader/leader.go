@@ -0,0 +1,57 @@
+// Package leader elects one gateway replica to run a singleton background
+// task, using a Redis key with a TTL as the lease.
+package leader
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// acquireScript extends the lease if we already hold it and otherwise tries
+// to take it.
+var acquireScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0
+`)
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type Lock struct {
+	redis *redis.Client
+	key   string
+	id    string
+	ttl   time.Duration
+}
+
+func NewLock(client *redis.Client, key string, ttl time.Duration) *Lock {
+	return &Lock{redis: client, key: key, id: uuid.NewString(), ttl: ttl}
+}
+
+// Acquire takes or renews the lease and reports whether this replica is
+// the leader until the TTL runs out.
+func (l *Lock) Acquire(ctx context.Context) (bool, error) {
+	n, err := acquireScript.Run(ctx, l.redis, []string{l.key}, l.id, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Release gives the lease up early so another replica can take over
+// without waiting for the TTL.
+func (l *Lock) Release(ctx context.Context) error {
+	return releaseScript.Run(ctx, l.redis, []string{l.key}, l.id).Err()
+}
@@ -0,0 +1,37 @@
+// Package mailer sends transactional email. The SMTP driver is for real
+// deployments; the memory driver keeps messages for tests and local runs.
+package mailer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/LunarTechAI/octavia/api-gateway/config"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the driver selected by MAIL_DRIVER.
+func New(cfg *config.Config) (Mailer, error) {
+	switch cfg.MailDriver {
+	case "smtp":
+		return NewSMTP(SMTPOptions{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}), nil
+	case "memory":
+		return NewMemory(), nil
+	}
+	return nil, fmt.Errorf("mailer: unknown driver %q", cfg.MailDriver)
+}
@@ -0,0 +1,174 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/LunarTechAI/octavia/api-gateway/config"
+)
+
+func TestNew(t *testing.T) {
+	if m, err := New(&config.Config{MailDriver: "memory"}); err != nil {
+		t.Errorf("memory: %v", err)
+	} else if _, ok := m.(*Memory); !ok {
+		t.Errorf("memory driver = %T", m)
+	}
+	if m, err := New(&config.Config{MailDriver: "smtp", SMTPHost: "mail.test", SMTPPort: 25}); err != nil {
+		t.Errorf("smtp: %v", err)
+	} else if _, ok := m.(*SMTP); !ok {
+		t.Errorf("smtp driver = %T", m)
+	}
+	if _, err := New(&config.Config{MailDriver: "carrier-pigeon"}); err == nil {
+		t.Error("unknown driver accepted")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	m := NewMemory()
+	ctx := context.Background()
+
+	if _, ok := m.Last("a@example.com"); ok {
+		t.Error("Last found a message before any were sent")
+	}
+	m.Send(ctx, Message{To: "a@example.com", Subject: "first"})
+	m.Send(ctx, Message{To: "b@example.com", Subject: "other"})
+	m.Send(ctx, Message{To: "a@example.com", Subject: "second"})
+
+	if msg, ok := m.Last("a@example.com"); !ok || msg.Subject != "second" {
+		t.Errorf("Last = %+v, %v; want the second message", msg, ok)
+	}
+	msgs := m.Messages()
+	if len(msgs) != 3 {
+		t.Fatalf("Messages returned %d messages, want 3", len(msgs))
+	}
+	msgs[0].Subject = "changed"
+	if m.Messages()[0].Subject != "first" {
+		t.Error("Messages returned the internal slice")
+	}
+}
+
+// fakeSMTP accepts one message and hands back the envelope and data.
+func fakeSMTP(t *testing.T) (host string, port int, received <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	out := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tp := textproto.NewConn(conn)
+		tp.PrintfLine("220 fake ESMTP")
+		var got []string
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.Fields(line + " ")[0])
+			switch cmd {
+			case "EHLO":
+				tp.PrintfLine("250 fake")
+			case "MAIL", "RCPT":
+				got = append(got, line)
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 go ahead")
+				data, err := tp.ReadDotLines()
+				if err != nil {
+					return
+				}
+				got = append(got, data...)
+				tp.PrintfLine("250 queued")
+			case "QUIT":
+				tp.PrintfLine("221 bye")
+				out <- got
+				return
+			default:
+				tp.PrintfLine("250 OK")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, out
+}
+
+func TestSMTPSend(t *testing.T) {
+	host, port, received := fakeSMTP(t)
+	m := NewSMTP(SMTPOptions{Host: host, Port: port, From: "Octavia <noreply@octavia.test>"})
+
+	err := m.Send(context.Background(), Message{
+		To:      "user@example.com",
+		Subject: "Hello\r\nBcc: victim@example.com",
+		Body:    "line one\n.line two",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var lines []string
+	select {
+	case lines = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("server received nothing")
+	}
+	text := strings.Join(lines, "\n")
+
+	for _, want := range []string{
+		"MAIL FROM:<noreply@octavia.test>",
+		"RCPT TO:<user@example.com>",
+		"To: user@example.com",
+		"Subject: Hello Bcc: victim@example.com",
+		"Content-Type: text/plain; charset=UTF-8",
+		"line one\n.line two",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("message is missing %q:\n%s", want, text)
+		}
+	}
+	for _, line := range lines {
+		if strings.HasPrefix(line, "Bcc:") {
+			t.Errorf("subject injected a header: %q", line)
+		}
+	}
+}
+
+func TestSMTPSendHonoursContext(t *testing.T) {
+	// A server that accepts but never greets leaves SendMail blocked.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err == nil {
+			bufio.NewReader(conn).ReadByte()
+			conn.Close()
+		}
+	}()
+
+	_, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	m := NewSMTP(SMTPOptions{Host: "127.0.0.1", Port: port, From: "noreply@octavia.test"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := m.Send(ctx, Message{To: "user@example.com"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Send = %v, want context.DeadlineExceeded", err)
+	}
+}
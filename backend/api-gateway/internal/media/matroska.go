@@ -0,0 +1,153 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	ebmlSegment       = 0x18538067
+	ebmlInfo          = 0x1549A966
+	ebmlTimecodeScale = 0x2AD7B1
+	ebmlDuration      = 0x4489
+	ebmlHeader        = 0x1A45DFA3
+
+	defaultTimecodeScale = 1000000 // ns
+)
+
+// probeMatroska reads Segment/Info/Duration from a WebM or Matroska file.
+func probeMatroska(r io.ReaderAt, size int64) (time.Duration, error) {
+	// Skip the EBML header element.
+	id, _, dataSize, off, err := readElement(r, 0, size)
+	if err != nil || id != ebmlHeader {
+		return 0, ErrMalformed
+	}
+	off += dataSize
+
+	segStart, segEnd, err := findElement(r, off, size, ebmlSegment)
+	if err != nil {
+		return 0, err
+	}
+	infoStart, infoEnd, err := findElement(r, segStart, segEnd, ebmlInfo)
+	if err != nil {
+		return 0, err
+	}
+
+	scale := uint64(defaultTimecodeScale)
+	duration := -1.0
+	for pos := infoStart; pos < infoEnd; {
+		id, _, dataSize, body, err := readElement(r, pos, infoEnd)
+		if err != nil {
+			return 0, err
+		}
+		// Both are at most 8-byte numbers; the size comes from the file, so
+		// check it before allocating.
+		if (id == ebmlTimecodeScale || id == ebmlDuration) && (dataSize < 1 || dataSize > 8) {
+			return 0, ErrMalformed
+		}
+		switch id {
+		case ebmlTimecodeScale:
+			buf := make([]byte, dataSize)
+			if err := readAt(r, buf, body); err != nil {
+				return 0, err
+			}
+			scale = readUint(buf)
+		case ebmlDuration:
+			buf := make([]byte, dataSize)
+			if err := readAt(r, buf, body); err != nil {
+				return 0, err
+			}
+			switch dataSize {
+			case 4:
+				duration = float64(math.Float32frombits(binary.BigEndian.Uint32(buf)))
+			case 8:
+				duration = math.Float64frombits(binary.BigEndian.Uint64(buf))
+			default:
+				return 0, ErrMalformed
+			}
+		}
+		pos = body + dataSize
+	}
+
+	if duration < 0 {
+		return 0, ErrMalformed
+	}
+	return time.Duration(duration * float64(scale)), nil
+}
+
+// findElement returns the data range of the first child with the given ID.
+// Segments written by live encoders may have an unknown size; those extend
+// to the end of the parent.
+func findElement(r io.ReaderAt, start, end int64, want uint64) (int64, int64, error) {
+	for pos := start; pos < end; {
+		id, unknown, dataSize, body, err := readElement(r, pos, end)
+		if err != nil {
+			return 0, 0, err
+		}
+		if unknown {
+			dataSize = end - body
+		}
+		if id == want {
+			return body, body + dataSize, nil
+		}
+		pos = body + dataSize
+	}
+	return 0, 0, ErrMalformed
+}
+
+// readElement decodes an element header at pos and returns its ID, whether
+// the size is "unknown", the data size and the offset of the data.
+func readElement(r io.ReaderAt, pos, end int64) (uint64, bool, int64, int64, error) {
+	id, idLen, err := readVint(r, pos, end, true)
+	if err != nil {
+		return 0, false, 0, 0, err
+	}
+	dataSize, sizeLen, err := readVint(r, pos+int64(idLen), end, false)
+	if err != nil {
+		return 0, false, 0, 0, err
+	}
+	unknown := dataSize == (uint64(1)<<(7*sizeLen))-1
+	body := pos + int64(idLen) + int64(sizeLen)
+	if !unknown && body+int64(dataSize) > end {
+		return 0, false, 0, 0, ErrMalformed
+	}
+	return id, unknown, int64(dataSize), body, nil
+}
+
+// readVint reads an EBML variable-length integer. IDs keep their length
+// marker bit; sizes have it stripped.
+func readVint(r io.ReaderAt, pos, end int64, keepMarker bool) (uint64, int, error) {
+	if pos >= end {
+		return 0, 0, ErrMalformed
+	}
+	first := make([]byte, 1)
+	if err := readAt(r, first, pos); err != nil {
+		return 0, 0, err
+	}
+	length := 1
+	for mask := byte(0x80); length <= 8 && first[0]&mask == 0; mask >>= 1 {
+		length++
+	}
+	if length > 8 {
+		return 0, 0, ErrMalformed
+	}
+
+	buf := make([]byte, length)
+	if err := readAt(r, buf, pos); err != nil {
+		return 0, 0, err
+	}
+	if !keepMarker {
+		buf[0] &= byte(0xFF >> length)
+	}
+	return readUint(buf), length, nil
+}
+
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
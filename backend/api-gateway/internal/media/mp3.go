@@ -0,0 +1,164 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// kbps, indexed by [version][layer][bitrate index]; version 0 = MPEG1,
+// 1 = MPEG2/2.5. Layer 0 = Layer I, 1 = II, 2 = III.
+var mp3Bitrates = [2][3][16]int{
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, 0},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, 0},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0},
+	},
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0},
+	},
+}
+
+// Hz, indexed by [MPEG version bits][sample rate index].
+var mp3SampleRates = map[byte][3]int{
+	3: {44100, 48000, 32000}, // MPEG1
+	2: {22050, 24000, 16000}, // MPEG2
+	0: {11025, 12000, 8000},  // MPEG2.5
+}
+
+const mp3SyncSearch = 64 * 1024
+
+type mp3Frame struct {
+	offset     int64
+	version    byte
+	layer      int
+	bitrate    int
+	sampleRate int
+	mono       bool
+}
+
+func (f *mp3Frame) samplesPerFrame() int {
+	switch {
+	case f.layer == 0:
+		return 384
+	case f.layer == 2 && f.version != 3:
+		return 576
+	}
+	return 1152
+}
+
+// probeMP3 uses the Xing/Info or VBRI frame count when present and falls
+// back to a constant-bitrate estimate from the first frame.
+func probeMP3(r io.ReaderAt, size int64) (time.Duration, error) {
+	start := int64(0)
+	id3 := make([]byte, 10)
+	if err := readAt(r, id3, 0); err == nil && string(id3[0:3]) == "ID3" {
+		tagSize := int64(id3[6]&0x7F)<<21 | int64(id3[7]&0x7F)<<14 | int64(id3[8]&0x7F)<<7 | int64(id3[9]&0x7F)
+		start = 10 + tagSize
+		if id3[5]&0x10 != 0 {
+			start += 10
+		}
+	}
+	if start >= size {
+		return 0, ErrMalformed
+	}
+
+	frame, err := findMP3Frame(r, start, size)
+	if err != nil {
+		return 0, err
+	}
+
+	if frames, ok := mp3VBRFrames(r, frame); ok {
+		total := float64(frames) * float64(frame.samplesPerFrame()) / float64(frame.sampleRate)
+		return seconds(total), nil
+	}
+
+	end := size
+	tag := make([]byte, 3)
+	if size >= 128 && readAt(r, tag, size-128) == nil && string(tag) == "TAG" {
+		end -= 128
+	}
+	audioBytes := end - frame.offset
+	return seconds(float64(audioBytes) * 8 / float64(frame.bitrate*1000)), nil
+}
+
+func findMP3Frame(r io.ReaderAt, start, size int64) (*mp3Frame, error) {
+	if start < 0 || start >= size {
+		return nil, ErrMalformed
+	}
+	limit := start + mp3SyncSearch
+	if limit > size {
+		limit = size
+	}
+	buf := make([]byte, limit-start)
+	n, err := r.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	buf = buf[:n]
+
+	for i := 0; i+4 <= len(buf); i++ {
+		if f := parseMP3Header(buf[i : i+4]); f != nil {
+			f.offset = start + int64(i)
+			return f, nil
+		}
+	}
+	return nil, ErrMalformed
+}
+
+func parseMP3Header(h []byte) *mp3Frame {
+	if h[0] != 0xFF || h[1]&0xE0 != 0xE0 {
+		return nil
+	}
+	version := (h[1] >> 3) & 0x03
+	layerBits := (h[1] >> 1) & 0x03
+	bitrateIdx := h[2] >> 4
+	rateIdx := (h[2] >> 2) & 0x03
+	if version == 1 || layerBits == 0 || bitrateIdx == 0 || bitrateIdx == 15 || rateIdx == 3 {
+		return nil
+	}
+
+	layer := int(3 - layerBits)
+	vIdx := 1
+	if version == 3 {
+		vIdx = 0
+	}
+	return &mp3Frame{
+		version:    version,
+		layer:      layer,
+		bitrate:    mp3Bitrates[vIdx][layer][bitrateIdx],
+		sampleRate: mp3SampleRates[version][rateIdx],
+		mono:       h[3]>>6 == 3,
+	}
+}
+
+func mp3VBRFrames(r io.ReaderAt, f *mp3Frame) (uint32, bool) {
+	// The Xing/Info tag sits right after the side information.
+	var side int64
+	switch {
+	case f.version == 3 && f.mono:
+		side = 17
+	case f.version == 3:
+		side = 32
+	case f.mono:
+		side = 9
+	default:
+		side = 17
+	}
+
+	xing := make([]byte, 12)
+	if readAt(r, xing, f.offset+4+side) == nil {
+		tag := string(xing[0:4])
+		if (tag == "Xing" || tag == "Info") && binary.BigEndian.Uint32(xing[4:8])&0x1 != 0 {
+			return binary.BigEndian.Uint32(xing[8:12]), true
+		}
+	}
+
+	vbri := make([]byte, 18)
+	if readAt(r, vbri, f.offset+4+32) == nil && string(vbri[0:4]) == "VBRI" {
+		return binary.BigEndian.Uint32(vbri[14:18]), true
+	}
+	return 0, false
+}
@@ -0,0 +1,81 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// probeMP4 reads the movie header (moov/mvhd) of an ISO BMFF / QuickTime file.
+func probeMP4(r io.ReaderAt, size int64) (time.Duration, error) {
+	moovStart, moovEnd, err := findBox(r, 0, size, "moov")
+	if err != nil {
+		return 0, err
+	}
+	mvhdStart, _, err := findBox(r, moovStart, moovEnd, "mvhd")
+	if err != nil {
+		return 0, err
+	}
+
+	version := make([]byte, 1)
+	if err := readAt(r, version, mvhdStart); err != nil {
+		return 0, err
+	}
+
+	var timescale uint32
+	var duration uint64
+	if version[0] == 1 {
+		// version(1) flags(3) creation(8) modification(8) timescale(4) duration(8)
+		buf := make([]byte, 12)
+		if err := readAt(r, buf, mvhdStart+20); err != nil {
+			return 0, err
+		}
+		timescale = binary.BigEndian.Uint32(buf[0:4])
+		duration = binary.BigEndian.Uint64(buf[4:12])
+	} else {
+		// version(1) flags(3) creation(4) modification(4) timescale(4) duration(4)
+		buf := make([]byte, 8)
+		if err := readAt(r, buf, mvhdStart+12); err != nil {
+			return 0, err
+		}
+		timescale = binary.BigEndian.Uint32(buf[0:4])
+		duration = uint64(binary.BigEndian.Uint32(buf[4:8]))
+	}
+
+	if timescale == 0 {
+		return 0, ErrMalformed
+	}
+	return seconds(float64(duration) / float64(timescale)), nil
+}
+
+// findBox scans sibling boxes in [start, end) and returns the payload range
+// of the first one of type typ.
+func findBox(r io.ReaderAt, start, end int64, typ string) (int64, int64, error) {
+	hdr := make([]byte, 16)
+	for off := start; off+8 <= end; {
+		if err := readAt(r, hdr[:8], off); err != nil {
+			return 0, 0, err
+		}
+		boxSize := int64(binary.BigEndian.Uint32(hdr[0:4]))
+		headerLen := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = end - off
+		case 1:
+			if err := readAt(r, hdr[8:16], off+8); err != nil {
+				return 0, 0, err
+			}
+			boxSize = int64(binary.BigEndian.Uint64(hdr[8:16]))
+			headerLen = 16
+		}
+		if boxSize < headerLen || off+boxSize > end {
+			return 0, 0, ErrMalformed
+		}
+
+		if string(hdr[4:8]) == typ {
+			return off + headerLen, off + boxSize, nil
+		}
+		off += boxSize
+	}
+	return 0, 0, ErrMalformed
+}
@@ -0,0 +1,72 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// An Ogg page is at most 27 + 255 + 255*255 bytes.
+const oggMaxPage = 65307
+
+// probeOgg divides the granule position of the last page by the codec's
+// sample rate, taken from the Vorbis or Opus identification header.
+func probeOgg(r io.ReaderAt, size int64) (time.Duration, error) {
+	hdr := make([]byte, 27)
+	if err := readAt(r, hdr, 0); err != nil {
+		return 0, err
+	}
+	serial := binary.LittleEndian.Uint32(hdr[14:18])
+	segments := int64(hdr[26])
+
+	packet := make([]byte, 20)
+	if err := readAt(r, packet, 27+segments); err != nil {
+		return 0, err
+	}
+
+	var rate float64
+	var preSkip int64
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x01vorbis")):
+		rate = float64(binary.LittleEndian.Uint32(packet[12:16]))
+	case bytes.HasPrefix(packet, []byte("OpusHead")):
+		// Opus granule positions always count 48 kHz samples.
+		rate = 48000
+		preSkip = int64(binary.LittleEndian.Uint16(packet[10:12]))
+	default:
+		return 0, ErrUnknownFormat
+	}
+	if rate == 0 {
+		return 0, ErrMalformed
+	}
+
+	granule, err := lastOggGranule(r, size, serial)
+	if err != nil {
+		return 0, err
+	}
+	return seconds(float64(granule-preSkip) / rate), nil
+}
+
+func lastOggGranule(r io.ReaderAt, size int64, serial uint32) (int64, error) {
+	start := size - oggMaxPage
+	if start < 0 {
+		start = 0
+	}
+	tail := make([]byte, size-start)
+	if err := readAt(r, tail, start); err != nil {
+		return 0, err
+	}
+
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		if i+27 > len(tail) {
+			continue
+		}
+		page := tail[i : i+27]
+		granule := int64(binary.LittleEndian.Uint64(page[6:14]))
+		if binary.LittleEndian.Uint32(page[14:18]) == serial && granule > 0 {
+			return granule, nil
+		}
+	}
+	return 0, ErrMalformed
+}
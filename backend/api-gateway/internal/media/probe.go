@@ -0,0 +1,104 @@
+// Package media reads container headers to find the playback duration of an
+// uploaded file without decoding it or shelling out to ffmpeg.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"time"
+)
+
+var (
+	ErrUnknownFormat = errors.New("media: unrecognised container format")
+	ErrMalformed     = errors.New("media: malformed or truncated header")
+)
+
+// Info is what Probe could learn about a file.
+type Info struct {
+	Format   string
+	Duration time.Duration
+}
+
+type prober func(r io.ReaderAt, size int64) (time.Duration, error)
+
+// Probe sniffs the container format of r and returns its duration. The
+// parsers read attacker-supplied headers, so a panic in one is reported as
+// ErrMalformed rather than taking the caller down.
+func Probe(r io.ReaderAt, size int64) (info *Info, err error) {
+	defer func() {
+		if recover() != nil {
+			info, err = nil, ErrMalformed
+		}
+	}()
+
+	head := make([]byte, 16)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	format, probe := detect(head)
+	if probe == nil {
+		return nil, ErrUnknownFormat
+	}
+
+	d, err := probe(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if d <= 0 {
+		return nil, ErrMalformed
+	}
+	return &Info{Format: format, Duration: d}, nil
+}
+
+func detect(head []byte) (string, prober) {
+	switch {
+	case len(head) >= 12 && bytes.Equal(head[0:4], []byte("RIFF")) && bytes.Equal(head[8:12], []byte("WAVE")):
+		return "wav", probeWAV
+	case len(head) >= 8 && isMP4Box(head[4:8]):
+		return "mp4", probeMP4
+	case len(head) >= 4 && bytes.Equal(head[0:4], []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return "matroska", probeMatroska
+	case len(head) >= 4 && bytes.Equal(head[0:4], []byte("OggS")):
+		return "ogg", probeOgg
+	case len(head) >= 3 && bytes.Equal(head[0:3], []byte("ID3")):
+		return "mp3", probeMP3
+	case len(head) >= 2 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return "mp3", probeMP3
+	}
+	return "", nil
+}
+
+func isMP4Box(typ []byte) bool {
+	switch string(typ) {
+	case "ftyp", "moov", "mdat", "free", "skip", "wide", "pnot":
+		return true
+	}
+	return false
+}
+
+// readAt reads exactly len(p) bytes at off or returns ErrMalformed.
+func readAt(r io.ReaderAt, p []byte, off int64) error {
+	n, err := r.ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		return ErrMalformed
+	}
+	return err
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Sniff reports the container format of head, which should be at least the
+// first 16 bytes of a file.
+func Sniff(head []byte) (string, bool) {
+	format, probe := detect(head)
+	return format, probe != nil
+}
@@ -0,0 +1,200 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func le16(v uint16) []byte { b := make([]byte, 2); binary.LittleEndian.PutUint16(b, v); return b }
+func le32(v uint32) []byte { b := make([]byte, 4); binary.LittleEndian.PutUint32(b, v); return b }
+func be32(v uint32) []byte { b := make([]byte, 4); binary.BigEndian.PutUint32(b, v); return b }
+
+func cat(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+// wavFile builds a RIFF/WAVE file with a PCM fmt chunk and dataLen bytes of
+// silence.
+func wavFile(sampleRate uint32, channels, bits uint16, byteRate uint32, dataLen int) []byte {
+	blockAlign := channels * bits / 8
+	fmtChunk := cat(le16(1), le16(channels), le32(sampleRate), le32(byteRate), le16(blockAlign), le16(bits))
+	body := cat(
+		[]byte("WAVE"),
+		[]byte("fmt "), le32(uint32(len(fmtChunk))), fmtChunk,
+		[]byte("data"), le32(uint32(dataLen)), make([]byte, dataLen),
+	)
+	return cat([]byte("RIFF"), le32(uint32(len(body))), body)
+}
+
+// mp3CBR builds an MPEG1 Layer III, 128 kbps, 44.1 kHz stream of n bytes,
+// optionally behind an ID3v2 tag whose header claims tagSize bytes.
+func mp3CBR(n int, id3 bool, tagSize uint32) []byte {
+	frame := []byte{0xFF, 0xFB, 0x90, 0x00}
+	audio := make([]byte, n)
+	copy(audio, frame)
+	if !id3 {
+		return audio
+	}
+	syncsafe := []byte{byte(tagSize >> 21 & 0x7F), byte(tagSize >> 14 & 0x7F), byte(tagSize >> 7 & 0x7F), byte(tagSize & 0x7F)}
+	return cat([]byte("ID3"), []byte{4, 0, 0}, syncsafe, audio)
+}
+
+func mp4Box(typ string, payload ...[]byte) []byte {
+	body := cat(payload...)
+	return cat(be32(uint32(8+len(body))), []byte(typ), body)
+}
+
+// mp4File builds ftyp + moov/mvhd (version 0).
+func mp4File(timescale, duration uint32) []byte {
+	mvhd := mp4Box("mvhd", []byte{0, 0, 0, 0}, be32(0), be32(0), be32(timescale), be32(duration), make([]byte, 80))
+	return cat(mp4Box("ftyp", []byte("isom"), be32(0x200)), mp4Box("moov", mvhd))
+}
+
+// ebml encodes an element whose ID bytes already carry the length marker.
+func ebml(id []byte, data []byte) []byte {
+	return cat(id, ebmlSize(uint64(len(data))), data)
+}
+
+func ebmlSize(n uint64) []byte {
+	// 8-byte size vint: 0x01 marker followed by 7 bytes.
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, n)
+	b[0] = 0x01
+	return b
+}
+
+func matroskaFile(scale uint64, durationTicks float64, durLen int) []byte {
+	scaleBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(scaleBytes, scale)
+	var dur []byte
+	switch durLen {
+	case 4:
+		dur = make([]byte, 4)
+		binary.BigEndian.PutUint32(dur, math.Float32bits(float32(durationTicks)))
+	default:
+		dur = make([]byte, durLen)
+		if durLen >= 8 {
+			binary.BigEndian.PutUint64(dur, math.Float64bits(durationTicks))
+		}
+	}
+	info := cat(ebml([]byte{0x2A, 0xD7, 0xB1}, scaleBytes), ebml([]byte{0x44, 0x89}, dur))
+	segment := ebml([]byte{0x18, 0x53, 0x80, 0x67}, ebml([]byte{0x15, 0x49, 0xA9, 0x66}, info))
+	header := ebml([]byte{0x1A, 0x45, 0xDF, 0xA3}, []byte{0x42, 0x82, 0x84, 'w', 'e', 'b', 'm'})
+	return cat(header, segment)
+}
+
+func oggPage(serial uint32, granule uint64, packet []byte) []byte {
+	hdr := cat([]byte("OggS"), []byte{0, 0}, make([]byte, 8), le32(serial), le32(0), le32(0), []byte{1, byte(len(packet))})
+	binary.LittleEndian.PutUint64(hdr[6:14], granule)
+	return cat(hdr, packet)
+}
+
+func vorbisFile(rate uint32, lastGranule uint64) []byte {
+	ident := cat([]byte("\x01vorbis"), le32(0), []byte{2}, le32(rate), make([]byte, 13))
+	return cat(oggPage(7, 0, ident), oggPage(7, lastGranule, []byte("audio")))
+}
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		format   string
+		duration time.Duration
+		err      error
+	}{
+		{"wav", wavFile(8000, 1, 16, 16000, 32000), "wav", 2 * time.Second, nil},
+		{"wav stereo", wavFile(44100, 2, 16, 176400, 176400), "wav", time.Second, nil},
+		{"wav inflated byte rate", wavFile(8000, 1, 16, 0x7FFFFFFF, 32000), "", 0, ErrMalformed},
+		{"wav zero sample rate", wavFile(0, 1, 16, 0, 32000), "", 0, ErrMalformed},
+		{"wav truncated fmt", wavFile(8000, 1, 16, 16000, 0)[:24], "", 0, ErrMalformed},
+		{"wav without data chunk", wavFile(8000, 1, 16, 16000, 0)[:36], "", 0, ErrMalformed},
+
+		{"mp3 cbr", mp3CBR(160000, false, 0), "mp3", 10 * time.Second, nil},
+		{"mp3 behind id3", mp3CBR(16000, true, 0), "mp3", time.Second, nil},
+		{"mp3 id3 tag larger than file", mp3CBR(16, true, 1<<27), "", 0, ErrMalformed},
+		{"mp3 id3 tag ends at eof", mp3CBR(0, true, 0), "", 0, ErrMalformed},
+		{"mp3 no frame", append([]byte("ID3\x04\x00\x00\x00\x00\x00\x00"), make([]byte, 100)...), "", 0, ErrMalformed},
+
+		{"mp4", mp4File(1000, 90500), "mp4", 90500 * time.Millisecond, nil},
+		{"mp4 zero timescale", mp4File(0, 1), "", 0, ErrMalformed},
+		{"mp4 truncated moov", mp4File(1000, 1)[:30], "", 0, ErrMalformed},
+		{"mp4 box larger than file", cat(mp4Box("ftyp", []byte("isom")), be32(1<<30), []byte("moov")), "", 0, ErrMalformed},
+
+		{"matroska float64", matroskaFile(1000000, 12000, 8), "matroska", 12 * time.Second, nil},
+		{"matroska float32", matroskaFile(1000000, 3000, 4), "matroska", 3 * time.Second, nil},
+		{"matroska oversized duration", matroskaFile(1000000, 1, 4096), "", 0, ErrMalformed},
+		{"matroska truncated", matroskaFile(1000000, 1, 8)[:40], "", 0, ErrMalformed},
+
+		{"ogg vorbis", vorbisFile(44100, 441000), "ogg", 10 * time.Second, nil},
+		{"ogg truncated", vorbisFile(44100, 1)[:20], "", 0, ErrMalformed},
+
+		{"unknown", []byte("not a media file at all"), "", 0, ErrUnknownFormat},
+		{"empty", nil, "", 0, ErrUnknownFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Format != tt.format {
+				t.Errorf("format = %q, want %q", info.Format, tt.format)
+			}
+			if diff := info.Duration - tt.duration; diff < -time.Millisecond || diff > time.Millisecond {
+				t.Errorf("duration = %v, want %v", info.Duration, tt.duration)
+			}
+		})
+	}
+}
+
+func TestProbeSubtitles(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		format   string
+		duration time.Duration
+		err      error
+	}{
+		{"srt", "1\n00:00:01,000 --> 00:00:02,500\nHi\n\n2\n00:01:02,000 --> 00:01:04,250\nBye\n", "srt", 64250 * time.Millisecond, nil},
+		{"vtt", "\xEF\xBB\xBFWEBVTT\n\n01:02.500 --> 01:05.000\nHi\n", "vtt", 65 * time.Second, nil},
+		{"vtt with hours", "WEBVTT\n\n01:00:00.000 --> 01:00:01.000\nHi\n", "vtt", time.Hour + time.Second, nil},
+		{"no cues", "just some text\n", "", 0, ErrUnknownFormat},
+		{"zero length cue", "1\n00:00:00,000 --> 00:00:00,000\nHi\n", "", 0, ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := ProbeSubtitles(bytes.NewReader([]byte(tt.data)), int64(len(tt.data)))
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if info.Format != tt.format || info.Duration != tt.duration {
+				t.Errorf("got %s %v, want %s %v", info.Format, info.Duration, tt.format, tt.duration)
+			}
+		})
+	}
+}
+
+func TestSniff(t *testing.T) {
+	if format, ok := Sniff(wavFile(8000, 1, 16, 16000, 0)[:16]); !ok || format != "wav" {
+		t.Errorf("Sniff(wav) = %q, %v", format, ok)
+	}
+	if _, ok := Sniff([]byte("hello")); ok {
+		t.Error("Sniff accepted plain text")
+	}
+}
@@ -0,0 +1,67 @@
+package media
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// maxSubtitleSize bounds how much of a subtitle file is scanned for cues.
+const maxSubtitleSize = 16 << 20
+
+// cueTiming matches SRT ("00:01:02,500 --> 00:01:04,000") and WebVTT
+// ("01:02.500 --> 01:04.000", hours optional) timing lines.
+var cueTiming = regexp.MustCompile(`^\s*(?:(\d+):)?(\d{1,2}):(\d{2})[,.](\d{3})\s*-->\s*(?:(\d+):)?(\d{1,2}):(\d{2})[,.](\d{3})`)
+
+// ProbeSubtitles reads an SRT or WebVTT file and returns the end of its last
+// cue as the duration.
+func ProbeSubtitles(r io.ReaderAt, size int64) (*Info, error) {
+	if size > maxSubtitleSize {
+		size = maxSubtitleSize
+	}
+	sc := bufio.NewScanner(io.NewSectionReader(r, 0, size))
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+
+	format := "srt"
+	var end time.Duration
+	cues := 0
+	for first := true; sc.Scan(); first = false {
+		line := sc.Bytes()
+		if first {
+			line = bytes.TrimPrefix(line, []byte("\xEF\xBB\xBF"))
+			if bytes.HasPrefix(line, []byte("WEBVTT")) {
+				format = "vtt"
+				continue
+			}
+		}
+		m := cueTiming.FindSubmatch(line)
+		if m == nil {
+			continue
+		}
+		cues++
+		if d := cueTime(m[5], m[6], m[7], m[8]); d > end {
+			end = d
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, ErrMalformed
+	}
+	if cues == 0 {
+		return nil, ErrUnknownFormat
+	}
+	if end <= 0 {
+		return nil, ErrMalformed
+	}
+	return &Info{Format: format, Duration: end}, nil
+}
+
+func cueTime(h, m, s, ms []byte) time.Duration {
+	atoi := func(b []byte) time.Duration {
+		n, _ := strconv.Atoi(string(b))
+		return time.Duration(n)
+	}
+	return atoi(h)*time.Hour + atoi(m)*time.Minute + atoi(s)*time.Second + atoi(ms)*time.Millisecond
+}
@@ -0,0 +1,66 @@
+package media
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// Limits for the fmt chunk: 768 kHz covers every real sample rate and 256
+// bytes per frame allows 32 channels of 64-bit samples.
+const (
+	wavMaxSampleRate = 768000
+	wavMaxBlockAlign = 256
+)
+
+// probeWAV walks RIFF chunks for "fmt " (byte rate) and "data" (length).
+// The byte rate must agree with sample rate times block align, so a header
+// cannot claim an inflated rate to shrink the billed duration.
+func probeWAV(r io.ReaderAt, size int64) (time.Duration, error) {
+	var byteRate uint32
+	var dataSize int64 = -1
+
+	hdr := make([]byte, 8)
+	for off := int64(12); off+8 <= size; {
+		if err := readAt(r, hdr, off); err != nil {
+			return 0, err
+		}
+		id := string(hdr[0:4])
+		chunkSize := int64(binary.LittleEndian.Uint32(hdr[4:8]))
+		body := off + 8
+
+		switch id {
+		case "fmt ":
+			fmtChunk := make([]byte, 16)
+			if err := readAt(r, fmtChunk, body); err != nil {
+				return 0, err
+			}
+			sampleRate := binary.LittleEndian.Uint32(fmtChunk[4:8])
+			blockAlign := binary.LittleEndian.Uint16(fmtChunk[12:14])
+			if sampleRate == 0 || sampleRate > wavMaxSampleRate || blockAlign == 0 || blockAlign > wavMaxBlockAlign {
+				return 0, ErrMalformed
+			}
+			byteRate = binary.LittleEndian.Uint32(fmtChunk[8:12])
+			if byteRate != sampleRate*uint32(blockAlign) {
+				return 0, ErrMalformed
+			}
+		case "data":
+			dataSize = chunkSize
+			// Streamed WAVs often leave the size as 0 or 0xFFFFFFFF.
+			if dataSize == 0 || dataSize == 0xFFFFFFFF || body+dataSize > size {
+				dataSize = size - body
+			}
+		}
+		if byteRate > 0 && dataSize >= 0 {
+			break
+		}
+
+		// Chunks are word-aligned.
+		off = body + chunkSize + chunkSize%2
+	}
+
+	if byteRate == 0 || dataSize < 0 {
+		return 0, ErrMalformed
+	}
+	return seconds(float64(dataSize) / float64(byteRate)), nil
+}
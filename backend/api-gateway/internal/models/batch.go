@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Batch groups the jobs submitted by one POST /jobs/batch so they can be
+// tracked and cancelled together. Its status is derived from the jobs.
+type Batch struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	JobCount  int
+	Cost      float64
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	Status string `gorm:"-"`
+	Jobs   []Job  `gorm:"-"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ReservationHeld     = "held"
+	ReservationCaptured = "captured"
+	ReservationReleased = "released"
+)
+
+// CreditReservation is the hold placed on a user's credits for one job. The
+// held amount has already left User.Credits; Released is whatever was
+// returned when the job failed or was cancelled.
+type CreditReservation struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key"`
+	JobID     uuid.UUID `gorm:"type:uuid;uniqueIndex;not null"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Amount    float64   `gorm:"not null"`
+	Released  float64
+	Status    string `gorm:"not null;default:'held'"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
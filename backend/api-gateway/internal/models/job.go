@@ -1,22 +1,65 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
 type Job struct {
-	ID            uuid.UUID `gorm:"type:uuid;primary_key"`
-	UserID        uuid.UUID `gorm:"not null"`
-	SourceFileURL string    `gorm:"not null"`
-	SourceLang    string    `gorm:"not null"`
-	TargetLang    string    `gorm:"not null"`
-	Duration      int64
-	Status        string `gorm:"default:'pending'"`
-	Cost          float64
-	ResultURL     string
-	Error         string
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+	ID     uuid.UUID `gorm:"type:uuid;primary_key"`
+	UserID uuid.UUID `gorm:"not null;index:idx_jobs_user_id"`
+
+	// SourceFileURL is the storage key of the source media. SourceRemoteURL
+	// keeps the client-supplied source_file_url for fetched sources.
+	// Kind is what the job produces (see package jobkind); Options holds
+	// its validated, defaulted settings.
+	Kind    string          `gorm:"not null;default:'audio_translation';index:idx_jobs_kind"`
+	Options json.RawMessage `gorm:"type:jsonb;not null;default:'{}'"`
+
+	// A job with several target languages is a parent (ChildCount > 0)
+	// holding the aggregate cost and status, with one child per language
+	// doing the work. TargetLang on a parent lists every language.
+	ParentID   *uuid.UUID `gorm:"type:uuid;index:idx_jobs_parent_id"`
+	ChildCount int
+	// BatchID is set on every job, parent or child, submitted in a batch.
+	BatchID *uuid.UUID `gorm:"type:uuid;index:idx_jobs_batch_id"`
+
+	SourceFileURL   string `gorm:"not null"`
+	SourceRemoteURL string
+	SourceFileName  string
+	SourceFileSize  int64
+	SourceChecksum  string
+	SourceMimeType  string
+
+	SourceLang string `gorm:"not null"`
+	TargetLang string `gorm:"type:text;not null"`
+
+	// Duration is probed from the media; ClaimedDuration is what the client sent.
+	Duration        int64
+	ClaimedDuration int64
+	DurationFlagged bool
+
+	Status string `gorm:"default:'pending';index:idx_jobs_status"`
+	// Attempts counts how often the reaper has requeued the job after its
+	// worker went silent.
+	Attempts int
+
+	// Progress as last reported by the worker. HeartbeatAt is when the
+	// gateway last heard from it.
+	Stage       string
+	Progress    int
+	ETA         *time.Time
+	HeartbeatAt *time.Time
+
+	Cost      float64
+	Refunded  float64
+	ResultURL string
+	Error     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	ResultDownloadURL string `gorm:"-"`
+	Children          []Job  `gorm:"-"`
 }
@@ -0,0 +1,101 @@
+package models
+
+const (
+	JobStatusFetching   = "fetching"
+	JobStatusPending    = "pending"
+	JobStatusProcessing = "processing"
+	JobStatusRetrying   = "retrying"
+	JobStatusCompleted  = "completed"
+	JobStatusFailed     = "failed"
+	JobStatusCancelled  = "cancelled"
+)
+
+// Pipeline stages a worker reports while a job is processing. Video jobs go
+// through all five; subtitle jobs skip synthesize.
+const (
+	JobStageExtract    = "extract"
+	JobStageTranscribe = "transcribe"
+	JobStageTranslate  = "translate"
+	JobStageSynthesize = "synthesize"
+	JobStageMerge      = "merge"
+)
+
+// ValidJobStage reports whether stage is one of the pipeline stages.
+func ValidJobStage(stage string) bool {
+	switch stage {
+	case JobStageExtract, JobStageTranscribe, JobStageTranslate, JobStageSynthesize, JobStageMerge:
+		return true
+	}
+	return false
+}
+
+// jobTransitions lists, for every status, the statuses a job may move to
+// next. Terminal statuses have no entry. fetching only leaves for pending
+// through the gateway's own fetcher, which holds the credits as it does, so
+// that move is not offered to callers.
+var jobTransitions = map[string][]string{
+	JobStatusFetching:   {JobStatusFailed, JobStatusCancelled},
+	JobStatusPending:    {JobStatusProcessing, JobStatusFailed, JobStatusCancelled},
+	JobStatusProcessing: {JobStatusProcessing, JobStatusCompleted, JobStatusFailed, JobStatusRetrying, JobStatusCancelled},
+	JobStatusRetrying:   {JobStatusProcessing, JobStatusFailed, JobStatusCancelled},
+}
+
+// ValidJobStatus reports whether status is one the API knows about.
+func ValidJobStatus(status string) bool {
+	switch status {
+	case JobStatusFetching, JobStatusPending, JobStatusProcessing, JobStatusRetrying,
+		JobStatusCompleted, JobStatusFailed, JobStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// CanTransition reports whether a job in status from may move to status to.
+func CanTransition(from, to string) bool {
+	for _, next := range jobTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CanRequeue reports whether the reaper or an operator replaying the
+// dead-letter queue may send a job in status back to pending. Workers never
+// move a job backwards, so these moves are not part of jobTransitions.
+func CanRequeue(status string) bool {
+	switch status {
+	case JobStatusPending, JobStatusProcessing, JobStatusRetrying, JobStatusFailed:
+		return true
+	}
+	return false
+}
+
+// IsTerminal reports whether no further transitions are possible from status.
+func IsTerminal(status string) bool {
+	_, ok := jobTransitions[status]
+	return !ok
+}
+
+// AggregateStatus derives a multi-language parent's status from its
+// children: in flight while any child is, otherwise failed if any child
+// failed, cancelled if all were, and completed.
+func AggregateStatus(children []string) string {
+	has := map[string]bool{}
+	for _, status := range children {
+		has[status] = true
+	}
+	switch {
+	case has[JobStatusProcessing] || has[JobStatusRetrying]:
+		return JobStatusProcessing
+	case has[JobStatusPending]:
+		return JobStatusPending
+	case has[JobStatusFetching]:
+		return JobStatusFetching
+	case has[JobStatusFailed]:
+		return JobStatusFailed
+	case has[JobStatusCompleted]:
+		return JobStatusCompleted
+	}
+	return JobStatusCancelled
+}
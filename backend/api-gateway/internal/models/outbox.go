@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OutboxMessage is a broker message written in the same database
+// transaction as the change it announces. The relay publishes it and stamps
+// SentAt once the broker has confirmed it.
+type OutboxMessage struct {
+	ID            uuid.UUID `gorm:"type:uuid;primary_key"`
+	Exchange      string
+	RoutingKey    string `gorm:"not null"`
+	MessageID     string `gorm:"not null"`
+	ContentType   string
+	Payload       []byte `gorm:"type:bytea;not null"`
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time `gorm:"not null;index:idx_outbox_messages_next_attempt_at"`
+	SentAt        *time.Time
+	CreatedAt     time.Time
+}
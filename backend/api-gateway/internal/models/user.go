@@ -6,6 +6,12 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	UserStatusPendingVerification = "pending_verification"
+	UserStatusActive              = "active"
+	UserStatusSuspended           = "suspended"
+)
+
 type User struct {
 	ID        uuid.UUID `gorm:"type:uuid;primary_key"`
 	Email     string    `gorm:"unique;not null"`
@@ -14,4 +20,19 @@ type User struct {
 	Credits   float64   `gorm:"default:0"`
 	CreatedAt time.Time
 	UpdatedAt time.Time
+
+	// Status defaults to active so accounts created before verification
+	// existed keep working; Signup sets pending_verification.
+	Status          string `gorm:"not null;default:'active'"`
+	EmailVerifiedAt *time.Time
+
+	AvatarURL string `gorm:"not null;default:''"`
+	Locale    string `gorm:"not null;default:'en'"`
+	// PendingEmail replaces Email once the new address is verified.
+	PendingEmail *string
+
+	// TOTPSecret is sealed by the mfa package; TOTPEnabledAt is set once
+	// enrolment is confirmed.
+	TOTPSecret    string `gorm:"not null;default:''"`
+	TOTPEnabledAt *time.Time
 }
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserIdentity links an account at an OIDC provider to a user. Subject is
+// the provider's stable user ID; Email is what the provider last reported.
+type UserIdentity struct {
+	ID        uuid.UUID `gorm:"type:uuid;primary_key"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Provider  string    `gorm:"not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Subject   string    `gorm:"not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Email     string    `gorm:"not null;default:''"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
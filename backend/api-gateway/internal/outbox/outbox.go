@@ -0,0 +1,160 @@
+// Package outbox gives at-least-once delivery from Postgres to RabbitMQ.
+// Handlers call Enqueue inside their database transaction; a Relay running
+// in the gateway publishes the rows with publisher confirms afterwards.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rabbitmq/amqp091-go"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/db"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+// Enqueue stores body as a JSON message for exchange and routingKey. It
+// only becomes visible to the relay when tx commits.
+func Enqueue(tx *gorm.DB, exchange, routingKey, messageID string, body interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return tx.Create(&models.OutboxMessage{
+		ID:            uuid.New(),
+		Exchange:      exchange,
+		RoutingKey:    routingKey,
+		MessageID:     messageID,
+		ContentType:   "application/json",
+		Payload:       payload,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}).Error
+}
+
+type Options struct {
+	PollInterval   time.Duration
+	BatchSize      int
+	ConfirmTimeout time.Duration
+	MaxBackoff     time.Duration
+	// Retention is how long sent rows are kept before being purged.
+	Retention time.Duration
+}
+
+// Relay drains the outbox table. Rows are claimed with SKIP LOCKED, so
+// several gateway replicas can run a relay side by side.
+type Relay struct {
+	db     *gorm.DB
+	broker *db.Broker
+	opts   Options
+}
+
+func NewRelay(database *gorm.DB, broker *db.Broker, opts Options) *Relay {
+	return &Relay{db: database, broker: broker, opts: opts}
+}
+
+// Run publishes pending messages until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.PollInterval)
+	defer ticker.Stop()
+
+	lastPurge := time.Now()
+	for {
+		// Keep draining while batches come back full.
+		for {
+			n, err := r.flush(ctx)
+			if err != nil {
+				log.Printf("outbox: relay: %v", err)
+			}
+			if err != nil || n < r.opts.BatchSize {
+				break
+			}
+		}
+
+		if time.Since(lastPurge) > time.Hour {
+			r.purge()
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// flush publishes one batch and returns how many rows it claimed.
+func (r *Relay) flush(ctx context.Context) (int, error) {
+	var claimed int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var msgs []models.OutboxMessage
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("sent_at IS NULL AND next_attempt_at <= ?", time.Now()).
+			Order("created_at").
+			Limit(r.opts.BatchSize).
+			Find(&msgs).Error
+		if err != nil {
+			return err
+		}
+		claimed = len(msgs)
+
+		for i := range msgs {
+			msg := &msgs[i]
+			if err := r.publish(ctx, msg); err != nil {
+				msg.Attempts++
+				msg.LastError = err.Error()
+				msg.NextAttemptAt = time.Now().Add(r.backoff(msg.Attempts))
+				if err := tx.Save(msg).Error; err != nil {
+					return err
+				}
+				// The rest of the batch would most likely fail the same way.
+				claimed = 0
+				return nil
+			}
+
+			now := time.Now()
+			msg.SentAt = &now
+			msg.LastError = ""
+			if err := tx.Save(msg).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+func (r *Relay) publish(ctx context.Context, msg *models.OutboxMessage) error {
+	ctx, cancel := context.WithTimeout(ctx, r.opts.ConfirmTimeout)
+	defer cancel()
+
+	return r.broker.Publish(ctx, msg.Exchange, msg.RoutingKey, amqp091.Publishing{
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp091.Persistent,
+		Body:         msg.Payload,
+		MessageId:    msg.MessageID,
+		Timestamp:    msg.CreatedAt,
+	})
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	d := time.Second << min(attempts, 16)
+	if d > r.opts.MaxBackoff {
+		d = r.opts.MaxBackoff
+	}
+	return d
+}
+
+func (r *Relay) purge() {
+	cutoff := time.Now().Add(-r.opts.Retention)
+	if err := r.db.Where("sent_at < ?", cutoff).Delete(&models.OutboxMessage{}).Error; err != nil {
+		log.Printf("outbox: purge failed: %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+// Package reaper recovers jobs whose worker stopped reporting. A job that
+// has sat in processing or retrying for longer than the lease is requeued,
+// and failed with a refund once it has used up its attempts. Jobs left in
+// fetching by a gateway that died mid-download are failed as well.
+package reaper
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/billing"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/dispatch"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/events"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/fanout"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/leader"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/models"
+)
+
+const batchSize = 100
+
+type Options struct {
+	Interval    time.Duration
+	Lease       time.Duration
+	MaxAttempts int
+	Refunds     billing.RefundPolicy
+	// FetchLease is how long a job may stay in fetching. It must outlast
+	// the fetcher's own timeout so only orphaned downloads are reaped.
+	FetchLease time.Duration
+}
+
+type Reaper struct {
+	db         *gorm.DB
+	dispatcher *dispatch.Dispatcher
+	events     *events.Bus
+	lock       *leader.Lock
+	opts       Options
+}
+
+func New(db *gorm.DB, dispatcher *dispatch.Dispatcher, bus *events.Bus, lock *leader.Lock, opts Options) *Reaper {
+	return &Reaper{db: db, dispatcher: dispatcher, events: bus, lock: lock, opts: opts}
+}
+
+// Run sweeps every interval on whichever replica holds the lock.
+func (r *Reaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+	defer r.lock.Release(context.Background())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		leading, err := r.lock.Acquire(ctx)
+		if err != nil {
+			log.Printf("reaper: leader lock: %v", err)
+			continue
+		}
+		if !leading {
+			continue
+		}
+		if err := r.Sweep(ctx); err != nil {
+			log.Printf("reaper: sweep failed: %v", err)
+		}
+	}
+}
+
+// Sweep handles every job whose lease has expired.
+func (r *Reaper) Sweep(ctx context.Context) error {
+	for {
+		n, err := r.sweepBatch(ctx)
+		if err != nil {
+			return err
+		}
+		if n < batchSize {
+			break
+		}
+	}
+	for {
+		n, err := r.sweepFetching(ctx)
+		if err != nil || n < batchSize {
+			return err
+		}
+	}
+}
+
+func (r *Reaper) sweepBatch(ctx context.Context) (int, error) {
+	var handled int
+	var changed []*models.Job
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var jobs []models.Job
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			// Multi-language parents only mirror their children.
+			Where("status IN ? AND updated_at < ? AND child_count = 0", []string{models.JobStatusProcessing, models.JobStatusRetrying}, time.Now().Add(-r.opts.Lease)).
+			Order("updated_at").
+			Limit(batchSize).
+			Find(&jobs).Error
+		if err != nil {
+			return err
+		}
+		handled = len(jobs)
+
+		for i := range jobs {
+			parent, err := r.reap(ctx, tx, &jobs[i])
+			if err != nil {
+				return err
+			}
+			changed = append(changed, &jobs[i])
+			if parent != nil {
+				changed = append(changed, parent)
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		r.publish(ctx, changed)
+	}
+	return handled, err
+}
+
+// reap requeues or fails job and returns its refreshed parent, if any.
+func (r *Reaper) reap(ctx context.Context, tx *gorm.DB, job *models.Job) (*models.Job, error) {
+	from := job.Status
+	if job.Attempts < r.opts.MaxAttempts {
+		log.Printf("reaper: requeueing job %s (attempt %d)", job.ID, job.Attempts+1)
+		err := tx.Model(job).Updates(map[string]interface{}{
+			"status":     models.JobStatusPending,
+			"attempts":   job.Attempts + 1,
+			"updated_at": time.Now(),
+			"stage":      "",
+			"progress":   0,
+			"eta":        nil,
+		}).Error
+		if err != nil {
+			return nil, err
+		}
+		if err := r.dispatcher.Enqueue(ctx, tx, job); err != nil {
+			return nil, err
+		}
+		return fanout.Refresh(tx, job)
+	}
+
+	log.Printf("reaper: failing job %s after %d attempts", job.ID, job.Attempts)
+	err := tx.Model(job).Updates(map[string]interface{}{
+		"status":     models.JobStatusFailed,
+		"error":      "worker stopped responding",
+		"updated_at": time.Now(),
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	if _, err := billing.Release(tx, job, r.opts.Refunds.Amount(job, from), "job_failed"); err != nil {
+		return nil, err
+	}
+	return fanout.Refresh(tx, job)
+}
+
+// sweepFetching fails jobs whose download was lost, typically because the
+// gateway running it restarted. No credits are held before the download
+// finishes, so there is nothing to refund.
+func (r *Reaper) sweepFetching(ctx context.Context) (int, error) {
+	var handled int
+	var changed []*models.Job
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var jobs []models.Job
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			// Children download through their parent.
+			Where("status = ? AND updated_at < ? AND parent_id IS NULL", models.JobStatusFetching, time.Now().Add(-r.opts.FetchLease)).
+			Order("updated_at").
+			Limit(batchSize).
+			Find(&jobs).Error
+		if err != nil {
+			return err
+		}
+		handled = len(jobs)
+
+		for _, job := range jobs {
+			log.Printf("reaper: failing job %s stuck in fetching", job.ID)
+			var failed []models.Job
+			err := tx.Model(&failed).Clauses(clause.Returning{}).Where("(id = ? OR parent_id = ?) AND status = ?", job.ID, job.ID, models.JobStatusFetching).Updates(map[string]interface{}{
+				"status":     models.JobStatusFailed,
+				"error":      "fetcher: download did not complete",
+				"updated_at": time.Now(),
+			}).Error
+			if err != nil {
+				return err
+			}
+			for i := range failed {
+				changed = append(changed, &failed[i])
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		r.publish(ctx, changed)
+	}
+	return handled, err
+}
+
+// publish tells streaming clients about jobs the reaper changed. It runs
+// after the commit, so nobody is told about a change that was rolled back.
+func (r *Reaper) publish(ctx context.Context, jobs []*models.Job) {
+	for _, job := range jobs {
+		if err := r.events.PublishJob(ctx, job); err != nil {
+			log.Printf("reaper: failed to publish event for %s: %v", job.ID, err)
+		}
+	}
+}
@@ -3,28 +3,52 @@ package server
 import (
 	"context"
 	"log"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/gofiber/fiber/v2/middleware/requestid"
-	"github.com/rabbitmq/amqp091-go"
 	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 
 	"github.com/LunarTechAI/octavia/api-gateway/config"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/billing"
 	"github.com/LunarTechAI/octavia/api-gateway/internal/db"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/dispatch"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/dlq"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/events"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/fetcher"
 	"github.com/LunarTechAI/octavia/api-gateway/internal/handlers"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/leader"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/mailer"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/outbox"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/reaper"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/storage"
+	"github.com/LunarTechAI/octavia/api-gateway/internal/tus"
 )
 
 type Server struct {
-	app           *fiber.App
-	db            *gorm.DB
-	redisClient   *redis.Client
-	rabbitConn    *amqp091.Connection
-	rabbitChannel *amqp091.Channel
-	cfg           *config.Config
+	app         *fiber.App
+	db          *gorm.DB
+	redisClient *redis.Client
+	broker      *db.Broker
+	storage     storage.Storage
+	uploads     *tus.Store
+	relay       *outbox.Relay
+	reaper      *reaper.Reaper
+	jobs        *handlers.JobsHandler
+	cfg         *config.Config
+
+	// ctx scopes background workers; stop cancels it on Shutdown.
+	ctx  context.Context
+	stop context.CancelFunc
+	// fetching is done once the fetch workers have returned.
+	fetching sync.WaitGroup
 }
 
 func NewServer(cfg *config.Config) (*Server, error) {
@@ -38,60 +62,173 @@ func NewServer(cfg *config.Config) (*Server, error) {
 		return nil, err
 	}
 
-	rabbitConn, rabbitChannel, err := db.InitRabbitMQ(cfg.RabbitMQURL, cfg.RabbitMQQueue, cfg.RabbitMQDLQ)
+	broker, err := db.InitRabbitMQ(cfg.RabbitMQURL, db.JobTopology(cfg.RabbitMQQueue, cfg.RabbitMQLegacyQueue, cfg.RabbitMQDLQ, cfg.RabbitMQDLX, cfg.RabbitMQCancelExchange), cfg.RabbitMQChannelPool)
 	if err != nil {
 		return nil, err
 	}
 
+	store, err := storage.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	relay := outbox.NewRelay(dbConn, broker, outbox.Options{
+		PollInterval:   time.Duration(cfg.OutboxPollInterval) * time.Millisecond,
+		BatchSize:      cfg.OutboxBatchSize,
+		ConfirmTimeout: 10 * time.Second,
+		MaxBackoff:     5 * time.Minute,
+		Retention:      7 * 24 * time.Hour,
+	})
+
+	uploads := tus.NewStore(redisClient, store, time.Duration(cfg.TusUploadTTL)*time.Second)
+	fetch := fetcher.New(store, fetcher.Options{
+		MaxSize:      cfg.FetchMaxSize,
+		Timeout:      time.Duration(cfg.FetchTimeout) * time.Second,
+		MaxRedirects: cfg.FetchMaxRedirects,
+		AllowPrivate: cfg.FetchAllowPrivate,
+	})
+
 	app := fiber.New(fiber.Config{
 		AppName:   "Octavia API Gateway",
-		BodyLimit: 1024 * 1024 * 100,
+		BodyLimit: maxBufferedBody,
+		// Lets upload bodies stream past BodyLimit instead of being buffered.
+		// Every other route is held to the limit by BodyLimitMiddleware.
+		StreamRequestBody: true,
 	})
 
 	app.Use(requestid.New())
 	app.Use(logger.New())
 	app.Use(recover.New())
+	app.Use(handlers.BodyLimitMiddleware(maxBufferedBody, streamedUpload))
 	app.Use(cors.New(cors.Config{
-		AllowHeaders: "X-Service-API-Key, X-Internal-API-Key, Content-Type, Accept, Origin",
+		AllowHeaders:  "X-Service-API-Key, X-Internal-API-Key, Content-Type, Accept, Origin, Tus-Resumable, Upload-Length, Upload-Metadata, Upload-Offset",
+		ExposeHeaders: "Location, Tus-Resumable, Tus-Version, Tus-Extension, Tus-Max-Size, Upload-Length, Upload-Offset, Upload-Expires",
 	}))
 
-	authHandler := handlers.NewAuthHandler(dbConn, redisClient, cfg)
-	jobsHandler := handlers.NewJobsHandler(dbConn, rabbitChannel, cfg)
+	dispatcher := dispatch.New(store, cfg.RabbitMQQueue)
+	bus := events.NewBus(redisClient)
+
+	reaperInterval := time.Duration(cfg.ReaperIntervalSeconds) * time.Second
+	jobReaper := reaper.New(dbConn, dispatcher, bus, leader.NewLock(redisClient, "reaper:leader", 2*reaperInterval), reaper.Options{
+		Interval:    reaperInterval,
+		Lease:       time.Duration(cfg.JobLeaseSeconds) * time.Second,
+		MaxAttempts: cfg.JobMaxAttempts,
+		Refunds:     billing.RefundPolicy{ProcessingRatio: cfg.RefundProcessingRatio},
+		// fetchSource gives up after FetchTimeout plus a minute for probing.
+		FetchLease: time.Duration(cfg.FetchTimeout)*time.Second + 2*time.Minute,
+	})
+
+	mail, err := mailer.New(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	authHandler := handlers.NewAuthHandler(dbConn, redisClient, mail, cfg)
+	jobsHandler := handlers.NewJobsHandler(dbConn, store, uploads, fetch, dispatcher, bus, cfg)
 	billingHandler := handlers.NewBillingHandler(dbConn, cfg)
+	filesHandler := handlers.NewFilesHandler(store)
+	uploadHandler := handlers.NewUploadHandler(store, cfg)
+	tusHandler := handlers.NewTusHandler(uploads, cfg)
+	healthHandler := handlers.NewHealthHandler(dbConn, redisClient, broker)
+	dlqHandler := handlers.NewDLQHandler(dbConn, dlq.New(broker, cfg.RabbitMQDLQ), dispatcher, bus, cfg)
+
+	registerRoutes(app, authHandler, jobsHandler, billingHandler, filesHandler, uploadHandler, tusHandler, healthHandler, dlqHandler, redisClient, cfg)
 
-	registerRoutes(app, authHandler, jobsHandler, billingHandler, redisClient, cfg)
+	ctx, stop := context.WithCancel(context.Background())
 
 	return &Server{
-		app:           app,
-		db:            dbConn,
-		redisClient:   redisClient,
-		rabbitConn:    rabbitConn,
-		rabbitChannel: rabbitChannel,
-		cfg:           cfg,
+		app:         app,
+		db:          dbConn,
+		redisClient: redisClient,
+		broker:      broker,
+		storage:     store,
+		uploads:     uploads,
+		relay:       relay,
+		reaper:      jobReaper,
+		jobs:        jobsHandler,
+		cfg:         cfg,
+		ctx:         ctx,
+		stop:        stop,
 	}, nil
 }
 
+const maxBufferedBody = 100 << 20
+
+// streamedUpload matches the routes that read their body as a stream: the
+// presigned local PUT, tus PATCH and signed result PUT. The first two check
+// the size themselves; the last is only signed for workers.
+func streamedUpload(c *fiber.Ctx) bool {
+	switch c.Method() {
+	case fiber.MethodPut:
+		return strings.HasPrefix(c.Path(), "/api/v1/upload/") || strings.HasPrefix(c.Path(), "/api/v1/files/")
+	case fiber.MethodPatch:
+		return strings.HasPrefix(c.Path(), "/api/v1/uploads/")
+	}
+	return false
+}
+
 func registerRoutes(
 	app *fiber.App,
 	authHandler *handlers.AuthHandler,
 	jobsHandler *handlers.JobsHandler,
 	billingHandler *handlers.BillingHandler,
+	filesHandler *handlers.FilesHandler,
+	uploadHandler *handlers.UploadHandler,
+	tusHandler *handlers.TusHandler,
+	healthHandler *handlers.HealthHandler,
+	dlqHandler *handlers.DLQHandler,
 	redisClient *redis.Client,
 	cfg *config.Config,
 ) {
 
+	app.Get("/healthz", healthHandler.Live)
+	app.Get("/readyz", healthHandler.Ready)
+
 	// PUBLIC & CLIENT ROUTES
 	api := app.Group("/api/v1")
 
 	auth := api.Group("/auth")
 	auth.Post("/signup", authHandler.Signup)
 	auth.Post("/login", authHandler.Login)
+	auth.Post("/login/2fa", authHandler.LoginTwoFactor)
+	auth.Post("/verify", authHandler.VerifyEmail)
+	auth.Post("/verify/resend", authHandler.ResendVerification)
+	auth.Post("/password/forgot", authHandler.ForgotPassword)
+	auth.Post("/password/reset", authHandler.ResetPassword)
+	auth.Get("/oidc", authHandler.ListSSOProviders)
+	auth.Get("/oidc/:provider", authHandler.StartSSO)
+	auth.Get("/oidc/:provider/callback", authHandler.SSOCallback)
+	auth.Post("/oidc/:provider/callback", authHandler.SSOCallback)
+
+	// Signed links for the local storage driver; the signature is the auth.
+	api.Get("/files/*", filesHandler.Download)
+	api.Put("/files/*", filesHandler.Upload)
+	api.Put("/upload/*", uploadHandler.LocalPut)
 
 	protected := api.Use(handlers.SessionAuthMiddleware(redisClient, cfg.SessionCookieName, cfg.SessionTTL))
 	protected.Post("/auth/logout", authHandler.Logout)
 	protected.Get("/auth/me", authHandler.GetMe)
-	protected.Post("/jobs", jobsHandler.CreateJob)
+	protected.Patch("/auth/me", authHandler.UpdateMe)
+	protected.Post("/auth/password", authHandler.ChangePassword)
+	protected.Post("/auth/2fa/setup", authHandler.SetupTwoFactor)
+	protected.Post("/auth/2fa/confirm", authHandler.ConfirmTwoFactor)
+	protected.Post("/auth/2fa/disable", authHandler.DisableTwoFactor)
+	protected.Post("/auth/2fa/recovery-codes", authHandler.RegenerateRecoveryCodes)
+	protected.Post("/upload/presign", uploadHandler.Presign)
+	protected.Options("/uploads", tusHandler.Options)
+	protected.Post("/uploads", tusHandler.Create)
+	protected.Head("/uploads/:id", tusHandler.Head)
+	protected.Patch("/uploads/:id", tusHandler.Patch)
+	protected.Delete("/uploads/:id", tusHandler.Delete)
+	protected.Post("/jobs", authHandler.RequireActive, jobsHandler.CreateJob)
+	protected.Post("/jobs/batch", authHandler.RequireActive, jobsHandler.CreateBatch)
+	protected.Get("/jobs", jobsHandler.ListJobs)
 	protected.Get("/jobs/:id", jobsHandler.GetJob)
+	protected.Post("/jobs/:id/cancel", jobsHandler.CancelJob)
+	protected.Get("/jobs/:id/events", jobsHandler.StreamEvents)
+	protected.Get("/jobs/:id/ws", jobsHandler.UpgradeJobSocket, websocket.New(jobsHandler.StreamJobSocket))
+	protected.Get("/batches/:id", jobsHandler.GetBatch)
+	protected.Post("/batches/:id/cancel", jobsHandler.CancelBatch)
 
 	service := api.Use(handlers.ServiceAuthMiddleware(cfg.ServiceAPIKey))
 	service.Post("/billing/credit", billingHandler.AddCredit)
@@ -101,19 +238,47 @@ func registerRoutes(
 	internal.Use(handlers.WorkerAuthMiddleware(cfg.InternalAPIKey))
 	internal.Patch("/jobs/:id", jobsHandler.UpdateJob)
 
+	// OPERATOR ROUTES
+	admin := app.Group("/api/admin")
+	admin.Use(handlers.AdminAuthMiddleware(cfg.AdminAPIKey))
+	admin.Get("/dlq", dlqHandler.List)
+	admin.Post("/dlq/requeue", dlqHandler.Requeue)
+	admin.Post("/dlq/discard", dlqHandler.Discard)
+	admin.Post("/dlq/:id/requeue", dlqHandler.RequeueOne)
+	admin.Delete("/dlq/:id", dlqHandler.DiscardOne)
+
 }
 
 func (s *Server) Start(addr string) error {
+	go s.uploads.Run(s.ctx, time.Minute)
+	go s.relay.Run(s.ctx)
+	go s.reaper.Run(s.ctx)
+	s.fetching.Add(1)
+	go func() {
+		defer s.fetching.Done()
+		s.jobs.RunFetches(s.ctx)
+	}()
 	return s.app.Listen(addr)
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
 	log.Println("Shutting down server...")
-	if s.rabbitChannel != nil {
-		s.rabbitChannel.Close()
+	s.stop()
+
+	// Interrupted downloads fail their jobs, which needs Redis for the events.
+	fetched := make(chan struct{})
+	go func() {
+		s.fetching.Wait()
+		close(fetched)
+	}()
+	select {
+	case <-fetched:
+	case <-ctx.Done():
+		log.Println("Shutdown: fetch workers did not stop in time")
 	}
-	if s.rabbitConn != nil {
-		s.rabbitConn.Close()
+
+	if s.broker != nil {
+		s.broker.Close()
 	}
 	if s.redisClient != nil {
 		s.redisClient.Close()
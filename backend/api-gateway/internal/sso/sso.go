@@ -0,0 +1,171 @@
+// Package sso signs users in through OpenID Connect providers using the
+// authorization code flow with PKCE. Provider metadata and signing keys are
+// discovered from the issuer on first use, so an unreachable provider does
+// not stop the gateway from starting.
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+
+	"github.com/LunarTechAI/octavia/api-gateway/config"
+)
+
+var ErrNonceMismatch = errors.New("sso: id token nonce does not match")
+
+// Identity is what a verified ID token says about the user.
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+type Provider struct {
+	Name        string
+	cfg         config.OIDCProvider
+	redirectURL string
+	client      *http.Client
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+type Registry struct {
+	providers map[string]*Provider
+}
+
+// NewRegistry sets up providers whose callbacks live under callbackBase,
+// at callbackBase/<name>/callback.
+func NewRegistry(providers []config.OIDCProvider, callbackBase string) *Registry {
+	r := &Registry{providers: map[string]*Provider{}}
+	client := &http.Client{Timeout: 10 * time.Second}
+	for _, p := range providers {
+		r.providers[p.Name] = &Provider{
+			Name:        p.Name,
+			cfg:         p,
+			redirectURL: callbackBase + "/" + p.Name + "/callback",
+			client:      client,
+		}
+	}
+	return r
+}
+
+func (r *Registry) Get(name string) (*Provider, bool) {
+	p, ok := r.providers[name]
+	return p, ok
+}
+
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// discover fetches the issuer's metadata once. Failures are retried on the
+// next call. The key set outlives the request, so it gets its own context
+// rather than the request's.
+func (p *Provider) discover() (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(context.Background(), p.client), p.cfg.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("sso: discover %s: %w", p.Name, err)
+	}
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.redirectURL,
+		Scopes:       p.cfg.Scopes,
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+	return p.oauth, p.verifier, nil
+}
+
+// AuthCodeURL is where to send the browser. verifier is the PKCE code
+// verifier; only its S256 challenge leaves the server.
+func (p *Provider) AuthCodeURL(state, nonce, verifier string) (string, error) {
+	oauth, _, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	opts := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(verifier), oidc.Nonce(nonce)}
+	if p.cfg.ResponseMode != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("response_mode", p.cfg.ResponseMode))
+	}
+	return oauth.AuthCodeURL(state, opts...), nil
+}
+
+// Exchange redeems code and verifies the returned ID token's signature,
+// issuer, audience, expiry and nonce.
+func (p *Provider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+	oauth, idVerifier, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+	ctx = oidc.ClientContext(ctx, p.client)
+
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("sso: exchange code with %s: %w", p.Name, err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("sso: %s returned no id_token", p.Name)
+	}
+	idToken, err := idVerifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("sso: verify %s id_token: %w", p.Name, err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrNonceMismatch
+	}
+
+	var claims struct {
+		Email         string      `json:"email"`
+		EmailVerified interface{} `json:"email_verified"`
+		Name          string      `json:"name"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("sso: parse %s claims: %w", p.Name, err)
+	}
+
+	return &Identity{
+		Provider:      p.Name,
+		Subject:       idToken.Subject,
+		Email:         claims.Email,
+		EmailVerified: truthy(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// truthy reads email_verified, which Apple sends as the string "true".
+func truthy(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		b, _ := strconv.ParseBool(v)
+		return b
+	}
+	return false
+}
@@ -0,0 +1,253 @@
+package sso
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc/oidctest"
+	"golang.org/x/oauth2"
+
+	"github.com/LunarTechAI/octavia/api-gateway/config"
+)
+
+// issuer is a stand-in OIDC provider. Its token endpoint checks the PKCE
+// verifier against the challenge from the authorization request and signs
+// whatever claims the test sets.
+type issuer struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	claims    map[string]interface{}
+}
+
+func newIssuer(t *testing.T) *issuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iss := &issuer{key: key}
+	discovery := &oidctest.Server{
+		PublicKeys: []oidctest.PublicKey{{PublicKey: key.Public(), KeyID: "test", Algorithm: "RS256"}},
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", discovery)
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != "good-code" || base64.RawURLEncoding.EncodeToString(sum[:]) != iss.challenge {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		claims, _ := json.Marshal(iss.claims)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     oidctest.SignIDToken(key, "test", "RS256", string(claims)),
+		})
+	})
+	iss.Server = httptest.NewServer(mux)
+	discovery.SetIssuer(iss.URL)
+	t.Cleanup(iss.Close)
+	return iss
+}
+
+func (iss *issuer) provider(t *testing.T, responseMode string) *Provider {
+	t.Helper()
+	r := NewRegistry([]config.OIDCProvider{{
+		Name:         "test",
+		Issuer:       iss.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"openid", "email"},
+		ResponseMode: responseMode,
+	}}, "https://app.test/api/v1/auth/oidc")
+	p, ok := r.Get("test")
+	if !ok {
+		t.Fatal("provider not registered")
+	}
+	return p
+}
+
+// authorize starts a flow and records the PKCE challenge the provider
+// would have stored.
+func (iss *issuer) authorize(t *testing.T, p *Provider, verifier, nonce string) url.Values {
+	t.Helper()
+	raw, err := p.AuthCodeURL("state", nonce, verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	iss.challenge = q.Get("code_challenge")
+	return q
+}
+
+func (iss *issuer) idClaims(nonce string, extra map[string]interface{}) {
+	iss.claims = map[string]interface{}{
+		"iss":   iss.URL,
+		"aud":   "client",
+		"sub":   "user-123",
+		"nonce": nonce,
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range extra {
+		iss.claims[k] = v
+	}
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	iss := newIssuer(t)
+	p := iss.provider(t, "form_post")
+	verifier := oauth2.GenerateVerifier()
+
+	q := iss.authorize(t, p, verifier, "n-1")
+	want := map[string]string{
+		"client_id":             "client",
+		"redirect_uri":          "https://app.test/api/v1/auth/oidc/test/callback",
+		"response_type":         "code",
+		"response_mode":         "form_post",
+		"scope":                 "openid email",
+		"state":                 "state",
+		"nonce":                 "n-1",
+		"code_challenge_method": "S256",
+	}
+	for k, v := range want {
+		if q.Get(k) != v {
+			t.Errorf("%s = %q, want %q", k, q.Get(k), v)
+		}
+	}
+	if q.Get("code_challenge") == "" || q.Get("code_challenge") == verifier || q.Has("code_verifier") {
+		t.Errorf("the verifier leaked into the authorization URL: %v", q)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	iss := newIssuer(t)
+	p := iss.provider(t, "")
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		code     string
+		verifier string
+		nonce    string
+		claims   map[string]interface{}
+		want     *Identity
+		err      error
+	}{
+		{
+			name:   "verified email",
+			code:   "good-code",
+			nonce:  "n-1",
+			claims: map[string]interface{}{"email": "a@example.com", "email_verified": true, "name": "Ada"},
+			want:   &Identity{Provider: "test", Subject: "user-123", Email: "a@example.com", EmailVerified: true, Name: "Ada"},
+		},
+		{
+			name:   "email_verified as a string",
+			code:   "good-code",
+			nonce:  "n-1",
+			claims: map[string]interface{}{"email": "a@privaterelay.appleid.com", "email_verified": "true"},
+			want:   &Identity{Provider: "test", Subject: "user-123", Email: "a@privaterelay.appleid.com", EmailVerified: true},
+		},
+		{
+			name:   "unverified email",
+			code:   "good-code",
+			nonce:  "n-1",
+			claims: map[string]interface{}{"email": "a@example.com"},
+			want:   &Identity{Provider: "test", Subject: "user-123", Email: "a@example.com"},
+		},
+		{name: "nonce mismatch", code: "good-code", nonce: "n-2", err: ErrNonceMismatch},
+		{name: "bad code", code: "bad-code", nonce: "n-1"},
+		{name: "wrong verifier", code: "good-code", verifier: oauth2.GenerateVerifier(), nonce: "n-1"},
+		{name: "wrong audience", code: "good-code", nonce: "n-1", claims: map[string]interface{}{"aud": "someone-else"}},
+		{name: "expired", code: "good-code", nonce: "n-1", claims: map[string]interface{}{"exp": time.Now().Add(-time.Minute).Unix()}},
+		{name: "other issuer", code: "good-code", nonce: "n-1", claims: map[string]interface{}{"iss": "https://evil.test"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verifier := oauth2.GenerateVerifier()
+			iss.authorize(t, p, verifier, "n-1")
+			iss.idClaims("n-1", tt.claims)
+			if tt.verifier != "" {
+				verifier = tt.verifier
+			}
+
+			got, err := p.Exchange(ctx, tt.code, verifier, tt.nonce)
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("Exchange succeeded with %+v", got)
+				}
+				if tt.err != nil && !errors.Is(err, tt.err) {
+					t.Fatalf("err = %v, want %v", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("identity = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoveryRetriesAfterFailure(t *testing.T) {
+	down := true
+	iss := newIssuer(t)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		http.Redirect(w, r, iss.URL+r.URL.Path, http.StatusTemporaryRedirect)
+	}))
+	defer proxy.Close()
+
+	p := NewRegistry([]config.OIDCProvider{{Name: "test", Issuer: proxy.URL, ClientID: "client"}}, "https://app.test").providers["test"]
+	if _, err := p.AuthCodeURL("s", "n", oauth2.GenerateVerifier()); err == nil {
+		t.Fatal("AuthCodeURL succeeded while discovery was failing")
+	}
+
+	// The issuer in the metadata must match the configured one, so once the
+	// provider is back discovery fails on that instead of on the outage.
+	down = false
+	_, err := p.AuthCodeURL("s", "n", oauth2.GenerateVerifier())
+	if err == nil || !strings.Contains(err.Error(), "issuer") {
+		t.Fatalf("AuthCodeURL = %v, want an issuer mismatch from a fresh discovery", err)
+	}
+}
+
+func TestRegistryNames(t *testing.T) {
+	r := NewRegistry([]config.OIDCProvider{{Name: "microsoft"}, {Name: "apple"}, {Name: "google"}}, "https://app.test")
+	if got := r.Names(); fmt.Sprint(got) != "[apple google microsoft]" {
+		t.Errorf("Names = %v", got)
+	}
+	if _, ok := r.Get("github"); ok {
+		t.Error("Get found an unconfigured provider")
+	}
+}
@@ -0,0 +1,182 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newLocal(t *testing.T) *Local {
+	t.Helper()
+	l, err := NewLocal(t.TempDir(), "http://gateway.test/api/v1/files/", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func TestCleanKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+		err  error
+	}{
+		{"uploads/u/j/in.mp4", "uploads/u/j/in.mp4", nil},
+		{"/uploads//u/./j/in.mp4", "uploads/u/j/in.mp4", nil},
+		{"../../etc/passwd", "etc/passwd", nil},
+		{"uploads/../../../etc/passwd", "etc/passwd", nil},
+		{"", "", ErrInvalidKey},
+		{"/", "", ErrInvalidKey},
+		{"..", "", ErrInvalidKey},
+	}
+
+	for _, tt := range tests {
+		got, err := CleanKey(tt.key)
+		if !errors.Is(err, tt.err) || got != tt.want {
+			t.Errorf("CleanKey(%q) = %q, %v; want %q, %v", tt.key, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestLocalRoundTrip(t *testing.T) {
+	l := newLocal(t)
+	ctx := context.Background()
+	key := UploadKey("user", "job", "in.mp3")
+
+	obj, err := l.Put(ctx, key, strings.NewReader("hello"), 5, "audio/mpeg")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if obj.Key != key || obj.Size != 5 || obj.ContentType != "audio/mpeg" {
+		t.Errorf("Put returned %+v", obj)
+	}
+
+	rc, err := l.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "hello" {
+		t.Errorf("Get = %q", data)
+	}
+
+	// Overwrites replace the object whole.
+	if _, err := l.Put(ctx, key, strings.NewReader("bye"), -1, "audio/mpeg"); err != nil {
+		t.Fatalf("Put overwrite: %v", err)
+	}
+	if obj, err := l.Stat(ctx, key); err != nil || obj.Size != 3 {
+		t.Errorf("Stat after overwrite = %+v, %v", obj, err)
+	}
+
+	if err := l.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := l.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing key = %v, want nil", err)
+	}
+	if _, err := l.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+	if _, err := l.Stat(ctx, "uploads/user"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat of a directory = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLocalPutShortWrite(t *testing.T) {
+	l := newLocal(t)
+	ctx := context.Background()
+
+	if _, err := l.Put(ctx, "a/b.wav", strings.NewReader("abc"), 10, ""); err == nil {
+		t.Fatal("Put accepted fewer bytes than declared")
+	}
+	if _, err := l.Stat(ctx, "a/b.wav"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("partial object is visible: %v", err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(l.root, "a"))
+	if len(entries) != 0 {
+		t.Errorf("temp files left behind: %v", entries)
+	}
+}
+
+func TestLocalPutFailedReader(t *testing.T) {
+	l := newLocal(t)
+	ctx := context.Background()
+	boom := errors.New("boom")
+
+	r := io.MultiReader(bytes.NewReader([]byte("abc")), &errReader{boom})
+	if _, err := l.Put(ctx, "a/b.wav", r, -1, ""); !errors.Is(err, boom) {
+		t.Fatalf("Put = %v, want %v", err, boom)
+	}
+	if _, err := l.Stat(ctx, "a/b.wav"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("partial object is visible: %v", err)
+	}
+}
+
+type errReader struct{ err error }
+
+func (r *errReader) Read([]byte) (int, error) { return 0, r.err }
+
+func TestLocalKeysStayUnderRoot(t *testing.T) {
+	l := newLocal(t)
+	p, err := l.Path("../../outside.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(p, l.root+string(filepath.Separator)) {
+		t.Errorf("Path escaped the root: %s", p)
+	}
+}
+
+func TestLocalSignedURL(t *testing.T) {
+	l := newLocal(t)
+	ctx := context.Background()
+	key := "uploads/u/j/in.mp4"
+
+	signed, err := l.SignedURL(ctx, http.MethodPut, key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "http://gateway.test/api/v1/files/" + key; u.Scheme+"://"+u.Host+u.Path != want {
+		t.Errorf("SignedURL = %s, want it under %s", signed, want)
+	}
+	q := u.Query()
+
+	if err := l.VerifySignedURL("put", key, q); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := l.VerifySignedURL(http.MethodGet, key, q); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("signature accepted for another method: %v", err)
+	}
+	if err := l.VerifySignedURL(http.MethodPut, "uploads/u/j/other.mp4", q); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("signature accepted for another key: %v", err)
+	}
+
+	tampered := url.Values{"expires": {"99999999999"}, "signature": q["signature"]}
+	if err := l.VerifySignedURL(http.MethodPut, key, tampered); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("signature accepted with a moved expiry: %v", err)
+	}
+
+	expired, _ := l.SignedURL(ctx, http.MethodGet, key, -time.Minute)
+	u, _ = url.Parse(expired)
+	if err := l.VerifySignedURL(http.MethodGet, key, u.Query()); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expired signature accepted: %v", err)
+	}
+
+	other, _ := NewLocal(t.TempDir(), "http://gateway.test/api/v1/files", "other-secret")
+	if err := other.VerifySignedURL(http.MethodPut, key, q); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("signature accepted under another secret: %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// newS3 skips NewS3's bucket check so signing can be tested offline; with a
+// region set, minio signs without asking the endpoint for one.
+func newS3(t *testing.T) *S3 {
+	t.Helper()
+	client, err := minio.New("s3.example.com", &minio.Options{
+		Creds:  credentials.NewStaticV4("access", "secret", ""),
+		Secure: true,
+		Region: "us-east-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &S3{client: client, bucket: "media"}
+}
+
+func TestS3SignedURL(t *testing.T) {
+	s := newS3(t)
+	ctx := context.Background()
+
+	for _, method := range []string{http.MethodGet, http.MethodPut} {
+		signed, err := s.SignedURL(ctx, method, "/uploads/../u/in.mp4", 15*time.Minute)
+		if err != nil {
+			t.Fatalf("SignedURL(%s): %v", method, err)
+		}
+		u, err := url.Parse(signed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Path != "/media/u/in.mp4" {
+			t.Errorf("SignedURL(%s) path = %s, want the cleaned key", method, u.Path)
+		}
+		if u.Query().Get("X-Amz-Expires") != "900" || u.Query().Get("X-Amz-Signature") == "" {
+			t.Errorf("SignedURL(%s) = %s, want a 900s presigned URL", method, signed)
+		}
+	}
+
+	if _, err := s.SignedURL(ctx, http.MethodDelete, "u/in.mp4", time.Minute); err == nil {
+		t.Error("SignedURL signed a DELETE")
+	}
+	if _, err := s.SignedURL(ctx, http.MethodGet, "", time.Minute); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("SignedURL with an empty key = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestS3SignedPost(t *testing.T) {
+	s := newS3(t)
+	key := UploadKey("u", "j", "in.mp4")
+
+	u, fields, err := s.SignedPost(context.Background(), key, "video/mp4", 1024, 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(u, "/media/") {
+		t.Errorf("SignedPost URL = %s, want the bucket", u)
+	}
+	if fields["key"] != key || fields["Content-Type"] != "video/mp4" || fields["x-amz-signature"] == "" {
+		t.Errorf("SignedPost fields = %v", fields)
+	}
+	policy, err := base64.StdEncoding.DecodeString(fields["policy"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cond := range []string{`["eq","$Content-Type","video/mp4"]`, `["content-length-range", 1, 1024]`} {
+		if !strings.Contains(string(policy), cond) {
+			t.Errorf("policy %s lacks %s", policy, cond)
+		}
+	}
+}
+
+func TestTranslateS3Error(t *testing.T) {
+	missing := minio.ErrorResponse{Code: "NoSuchKey", StatusCode: http.StatusNotFound}
+	if err := translateS3Error(missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("NoSuchKey = %v, want ErrNotFound", err)
+	}
+	denied := minio.ErrorResponse{Code: "AccessDenied", StatusCode: http.StatusForbidden}
+	if err := translateS3Error(denied); errors.Is(err, ErrNotFound) {
+		t.Errorf("AccessDenied translated to ErrNotFound")
+	}
+}
+
+// newFakeS3 runs the driver against an in-memory S3 server, going through
+// NewS3 so the bucket is created the way it is in production.
+func newFakeS3(t *testing.T) *S3 {
+	t.Helper()
+	srv := httptest.NewServer(gofakes3.New(s3mem.New()).Server())
+	t.Cleanup(srv.Close)
+	s, err := NewS3(S3Options{
+		Endpoint:  strings.TrimPrefix(srv.URL, "http://"),
+		AccessKey: "access",
+		SecretKey: "secret",
+		Bucket:    "media",
+		Region:    "us-east-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	s := newFakeS3(t)
+	ctx := context.Background()
+	key := UploadKey("u", "j", "in.wav")
+
+	obj, err := s.Put(ctx, key, strings.NewReader("RIFF data"), 9, "audio/wav")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if obj.Key != key || obj.Size != 9 {
+		t.Errorf("Put = %+v", obj)
+	}
+
+	stat, err := s.Stat(ctx, key)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if stat.Size != 9 || stat.ContentType != "audio/wav" {
+		t.Errorf("Stat = %+v, want 9 bytes of audio/wav", stat)
+	}
+
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	body, err := io.ReadAll(r)
+	r.Close()
+	if err != nil || string(body) != "RIFF data" {
+		t.Errorf("Get = %q, %v", body, err)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s.Stat(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat after Delete = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete = %v, want ErrNotFound", err)
+	}
+}
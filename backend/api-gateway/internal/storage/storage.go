@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/LunarTechAI/octavia/api-gateway/config"
+)
+
+var (
+	ErrNotFound   = errors.New("storage: object not found")
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Object describes a stored blob.
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ETag        string
+	ModTime     time.Time
+}
+
+// Storage is the blob store shared by the gateway and the workers. Keys are
+// slash-separated and relative to the store root, e.g. "uploads/<user>/<job>/in.mp4".
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) (*Object, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (*Object, error)
+	Delete(ctx context.Context, key string) error
+	SignedURL(ctx context.Context, method, key string, expiry time.Duration) (string, error)
+}
+
+// URLVerifier is implemented by drivers whose signed URLs point back at the
+// gateway rather than at an external object store.
+type URLVerifier interface {
+	VerifySignedURL(method, key string, query url.Values) error
+}
+
+// PostSigner is implemented by drivers that can presign a form POST upload.
+// Unlike a presigned PUT, the policy binds the content type and a size limit,
+// so the store itself rejects anything else.
+type PostSigner interface {
+	SignedPost(ctx context.Context, key, contentType string, maxSize int64, expiry time.Duration) (string, map[string]string, error)
+}
+
+func New(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case "", "local":
+		return NewLocal(cfg.StoragePath, cfg.PublicURL+"/api/v1/files", cfg.StorageSigningSecret)
+	case "s3":
+		return NewS3(S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			UseSSL:    cfg.S3UseSSL,
+		})
+	default:
+		return nil, fmt.Errorf("storage: unknown driver %q", cfg.StorageDriver)
+	}
+}
+
+func UploadKey(userID, jobID, name string) string {
+	return path.Join("uploads", userID, jobID, name)
+}
+
+// TusUploadKey is where a finished resumable upload is assembled. It lives
+// outside uploads/ so it can only become a job source through its upload_id.
+func TusUploadKey(userID, uploadID, name string) string {
+	return path.Join("tus-uploads", userID, uploadID, name)
+}
+
+// ResultKey is where a worker stores what it produced for a job.
+func ResultKey(userID, jobID, ext string) string {
+	return path.Join("results", userID, jobID+"."+ext)
+}
+
+// CleanKey normalises a key and rejects anything that would escape the root.
+func CleanKey(key string) (string, error) {
+	key = strings.TrimPrefix(path.Clean("/"+key), "/")
+	if key == "" || key == "." {
+		return "", ErrInvalidKey
+	}
+	return key, nil
+}
@@ -0,0 +1,434 @@
+package tus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/LunarTechAI/octavia/api-gateway/internal/storage"
+)
+
+var (
+	ErrNotFound       = errors.New("tus: upload not found")
+	ErrOffsetMismatch = errors.New("tus: offset mismatch")
+	ErrLocked         = errors.New("tus: upload is locked by another request")
+	ErrTooLarge       = errors.New("tus: chunk exceeds upload length")
+	ErrCompleted      = errors.New("tus: upload already completed")
+)
+
+const (
+	expiringKey = "tus:expiring"
+	lockTTL     = 5 * time.Minute
+	// claimTTL keeps a claimed upload away from the sweeper for at least
+	// as long as creating its job can take.
+	claimTTL = 5 * time.Minute
+)
+
+// claimScript marks an existing upload as claimed, unless another request
+// already claimed it, and pushes its expiry out to ARGV[2].
+var claimScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+if redis.call("HSETNX", KEYS[1], "claimed", 1) == 0 then
+	return 0
+end
+if tonumber(redis.call("HGET", KEYS[1], "expires_at")) < tonumber(ARGV[2]) then
+	redis.call("HSET", KEYS[1], "expires_at", ARGV[2])
+	redis.call("ZADD", KEYS[2], ARGV[2], ARGV[1])
+end
+return 1
+`)
+
+// refreshLockScript extends the lock in KEYS[1] to ARGV[2] milliseconds, and
+// unlockScript deletes it, both only while it still holds the token ARGV[1].
+var refreshLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Upload is the Redis-backed state of one resumable upload. Chunks are stored
+// as separate objects under tus/<id>/ and concatenated into Key, under
+// tus-uploads/, once Offset reaches Length.
+type Upload struct {
+	ID        string
+	UserID    string
+	Length    int64
+	Offset    int64
+	FileName  string
+	FileType  string
+	Chunks    int
+	Key       string
+	ExpiresAt time.Time
+	// Claimed is set while a job that uses the upload is being created.
+	Claimed bool
+}
+
+func (u *Upload) Completed() bool {
+	return u.Key != ""
+}
+
+type Store struct {
+	redis   *redis.Client
+	storage storage.Storage
+	ttl     time.Duration
+}
+
+func NewStore(redisClient *redis.Client, store storage.Storage, ttl time.Duration) *Store {
+	return &Store{redis: redisClient, storage: store, ttl: ttl}
+}
+
+func uploadKey(id string) string { return "tus:upload:" + id }
+func lockKey(id string) string   { return "tus:lock:" + id }
+func chunkKey(id string, n int) string {
+	return fmt.Sprintf("tus/%s/%08d", id, n)
+}
+
+func (s *Store) Create(ctx context.Context, userID string, length int64, fileName, fileType string) (*Upload, error) {
+	u := &Upload{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Length:    length,
+		FileName:  fileName,
+		FileType:  fileType,
+		ExpiresAt: time.Now().Add(s.ttl),
+	}
+	if err := s.save(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *Store) Get(ctx context.Context, id string) (*Upload, error) {
+	vals, err := s.redis.HGetAll(ctx, uploadKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) == 0 {
+		return nil, ErrNotFound
+	}
+
+	u := &Upload{
+		ID:       id,
+		UserID:   vals["user_id"],
+		FileName: vals["file_name"],
+		FileType: vals["file_type"],
+		Key:      vals["key"],
+		Claimed:  vals["claimed"] != "",
+	}
+	u.Length, _ = strconv.ParseInt(vals["length"], 10, 64)
+	u.Offset, _ = strconv.ParseInt(vals["offset"], 10, 64)
+	u.Chunks, _ = strconv.Atoi(vals["chunks"])
+	expires, _ := strconv.ParseInt(vals["expires_at"], 10, 64)
+	u.ExpiresAt = time.Unix(expires, 0)
+
+	if time.Now().After(u.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return u, nil
+}
+
+// Append writes one PATCH body as the next chunk. When the upload is complete
+// the chunks are assembled into tus-uploads/<user>/<id>/<file name>.
+func (s *Store) Append(ctx context.Context, id string, offset int64, r io.Reader, size int64) (*Upload, error) {
+	unlock, err := s.lock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	u, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if u.Completed() {
+		return nil, ErrCompleted
+	}
+	if offset != u.Offset {
+		return nil, ErrOffsetMismatch
+	}
+	remaining := u.Length - u.Offset
+	if size > remaining {
+		return nil, ErrTooLarge
+	}
+
+	obj, err := s.storage.Put(ctx, chunkKey(id, u.Chunks), &limitReader{r: r, n: remaining}, size, "application/offset+octet-stream")
+	if err != nil {
+		return nil, err
+	}
+	u.Offset += obj.Size
+	u.Chunks++
+	u.ExpiresAt = time.Now().Add(s.ttl)
+
+	if u.Offset == u.Length {
+		if err := s.assemble(ctx, u); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.save(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *Store) assemble(ctx context.Context, u *Upload) error {
+	key := storage.TusUploadKey(u.UserID, u.ID, u.FileName)
+	r := &chunkReader{ctx: ctx, storage: s.storage, id: u.ID, count: u.Chunks}
+	defer r.Close()
+
+	contentType := u.FileType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if _, err := s.storage.Put(ctx, key, r, u.Length, contentType); err != nil {
+		return err
+	}
+	s.deleteChunks(ctx, u.ID, u.Chunks)
+	u.Key = key
+	return nil
+}
+
+func (s *Store) Terminate(ctx context.Context, id string) error {
+	u, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if u.Claimed {
+		return ErrLocked
+	}
+	s.deleteChunks(ctx, id, u.Chunks)
+	if u.Completed() {
+		s.storage.Delete(ctx, u.Key)
+	}
+	return s.forget(ctx, id)
+}
+
+// Claim reserves a completed upload for a job that is about to be created
+// and returns its storage key. Only one caller can hold the claim. It ends
+// with Consume once the job is committed, or Release if it is not.
+func (s *Store) Claim(ctx context.Context, id, userID string) (string, error) {
+	u, err := s.Get(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	if u.UserID != userID || !u.Completed() {
+		return "", ErrNotFound
+	}
+	minExpiry := time.Now().Add(claimTTL).Unix()
+	claimed, err := claimScript.Run(ctx, s.redis, []string{uploadKey(id), expiringKey}, id, minExpiry).Int()
+	if err != nil {
+		return "", err
+	}
+	if claimed == 0 {
+		return "", ErrNotFound
+	}
+	return u.Key, nil
+}
+
+// Release drops a claim so the upload can be attached to a job again.
+func (s *Store) Release(ctx context.Context, id string) error {
+	return s.redis.HDel(ctx, uploadKey(id), "claimed").Err()
+}
+
+// Consume forgets a claimed upload whose job has been committed. The
+// assembled object now belongs to the job and is no longer swept.
+func (s *Store) Consume(ctx context.Context, id string) error {
+	return s.forget(ctx, id)
+}
+
+// SweepExpired removes chunks and state of uploads whose expiry has passed.
+func (s *Store) SweepExpired(ctx context.Context) (int, error) {
+	ids, err := s.redis.ZRangeByScore(ctx, expiringKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(time.Now().Unix(), 10),
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, id := range ids {
+		vals, err := s.redis.HMGet(ctx, uploadKey(id), "chunks", "key").Result()
+		if err != nil {
+			return 0, err
+		}
+		if chunks, ok := vals[0].(string); ok {
+			n, _ := strconv.Atoi(chunks)
+			s.deleteChunks(ctx, id, n)
+		}
+		if key, ok := vals[1].(string); ok && key != "" {
+			s.storage.Delete(ctx, key)
+		}
+		s.forget(ctx, id)
+	}
+	return len(ids), nil
+}
+
+// Run sweeps abandoned uploads every interval until ctx is cancelled.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if n, err := s.SweepExpired(ctx); err != nil {
+				log.Printf("tus: sweep failed: %v", err)
+			} else if n > 0 {
+				log.Printf("tus: removed %d expired uploads", n)
+			}
+		}
+	}
+}
+
+// lock takes the upload's PATCH lock and keeps it alive until unlock is
+// called, since a slow client can take longer than lockTTL to send a chunk.
+func (s *Store) lock(ctx context.Context, id string) (func(), error) {
+	token := uuid.New().String()
+	ok, err := s.redis.SetNX(ctx, lockKey(id), token, lockTTL).Result()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrLocked
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(lockTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := refreshLockScript.Run(context.Background(), s.redis, []string{lockKey(id)}, token, lockTTL.Milliseconds()).Err(); err != nil {
+					log.Printf("tus: failed to refresh lock on %s: %v", id, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+		unlockScript.Run(context.Background(), s.redis, []string{lockKey(id)}, token)
+	}, nil
+}
+
+func (s *Store) save(ctx context.Context, u *Upload) error {
+	pipe := s.redis.TxPipeline()
+	pipe.HSet(ctx, uploadKey(u.ID), map[string]interface{}{
+		"user_id":    u.UserID,
+		"length":     u.Length,
+		"offset":     u.Offset,
+		"file_name":  u.FileName,
+		"file_type":  u.FileType,
+		"chunks":     u.Chunks,
+		"key":        u.Key,
+		"expires_at": u.ExpiresAt.Unix(),
+	})
+	// Finished uploads stay here until CreateJob consumes them, so the
+	// sweeper also reaps assembled objects that no job ever claimed.
+	pipe.ZAdd(ctx, expiringKey, redis.Z{Score: float64(u.ExpiresAt.Unix()), Member: u.ID})
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *Store) forget(ctx context.Context, id string) error {
+	pipe := s.redis.TxPipeline()
+	pipe.Del(ctx, uploadKey(id))
+	pipe.ZRem(ctx, expiringKey, id)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (s *Store) deleteChunks(ctx context.Context, id string, count int) {
+	for i := 0; i < count; i++ {
+		s.storage.Delete(ctx, chunkKey(id, i))
+	}
+}
+
+// chunkReader streams tus/<id>/00000000..count-1 in order, opening each
+// object only when the previous one is exhausted.
+type chunkReader struct {
+	ctx     context.Context
+	storage storage.Storage
+	id      string
+	count   int
+	next    int
+	cur     io.ReadCloser
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if c.cur == nil {
+			if c.next >= c.count {
+				return 0, io.EOF
+			}
+			r, err := c.storage.Get(c.ctx, chunkKey(c.id, c.next))
+			if err != nil {
+				return 0, err
+			}
+			c.cur = r
+			c.next++
+		}
+		n, err := c.cur.Read(p)
+		if err == io.EOF {
+			c.cur.Close()
+			c.cur = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (c *chunkReader) Close() error {
+	if c.cur != nil {
+		return c.cur.Close()
+	}
+	return nil
+}
+
+// limitReader errors instead of silently truncating once n bytes are read.
+type limitReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		var one [1]byte
+		if n, _ := l.r.Read(one[:]); n > 0 {
+			return 0, ErrTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}